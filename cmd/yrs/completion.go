@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+// recentVideos is the number of videos offered when completing video IDs
+const recentVideos = 100
+
+// completionApp opens the database for completing arguments, to be closed
+// afterwards. Unlike PersistentPreRunE, it doesn't create the config file when
+// missing, as anything printed would end up among the completions, nor
+// migrates the database, offering nothing until some command does.
+func completionApp() (*yrs.Yrs, error) {
+	path, _, err := config.Path(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := yrs.PendingMigrations(c.DatabaseDriver, c.DatabaseUrl)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("%d migrations pending", len(pending))
+	}
+	return yrs.Open(c.DatabaseDriver, c.DatabaseUrl)
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// completeChannels completes the first argument with the names and IDs of
+// the channels
+func completeChannels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return channelCompletions(toComplete, true), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeChannelIDs is like completeChannels, for commands taking IDs only
+func completeChannelIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return channelCompletions(toComplete, false), cobra.ShellCompDirectiveNoFileComp
+}
+
+func channelCompletions(toComplete string, names bool) []string {
+	y, err := completionApp()
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil
+	}
+	defer y.Close()
+
+	channels, err := y.GetChannels()
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil
+	}
+
+	completions := make([]string, 0)
+	for _, c := range channels {
+		if names && hasPrefixFold(c.Name, toComplete) {
+			completions = append(completions, c.Name+"\t"+c.URL)
+		}
+		if strings.HasPrefix(c.ID, toComplete) {
+			completions = append(completions, c.ID+"\t"+c.Name)
+		}
+	}
+	return completions
+}
+
+// completeVideos completes any number of arguments with the IDs of the most
+// recent videos, skipping the ones already given
+func completeVideos(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	y, err := completionApp()
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer y.Close()
+
+	videos, err := y.QueryVideos(yrs.VideoQuery{Sort: yrs.SortNewest, Limit: recentVideos})
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	completions := make([]string, 0)
+	for _, v := range videos {
+		if strings.HasPrefix(v.ID, toComplete) && !slices.Contains(args, v.ID) {
+			completions = append(completions, v.ID+"\t"+v.Title)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeVideo is like completeVideos, for commands taking a single video
+func completeVideo(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeVideos(cmd, args, toComplete)
+}
+
+// completePlaylist completes the first argument with the names of the
+// playlists
+func completePlaylist(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	y, err := completionApp()
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer y.Close()
+
+	playlists, err := y.GetPlaylists()
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	completions := make([]string, 0)
+	for _, p := range playlists {
+		if hasPrefixFold(p.Name, toComplete) {
+			completions = append(completions, p.Name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePlaylistVideos completes a playlist followed by videos to add to it
+func completePlaylistVideos(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completePlaylist(cmd, args, toComplete)
+	}
+	return completeVideos(cmd, args[1:], toComplete)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miquelruiz/yrs/internal/config"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Show and edit the config file",
+	}
+
+	configPathCmd = &cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the config file in use",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _, err := config.Path(ConfigPath)
+			if err != nil {
+				return err
+			}
+			fmt.Println(path)
+			return nil
+		},
+	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show [setting]",
+		Short: "Print the settings in use, including defaults and environment overrides",
+		Args:  cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: showConfig,
+	}
+
+	configSetCmd = &cobra.Command{
+		Use:   "set <setting> <value>",
+		Short: "Change a setting in the config file",
+		Long: "Change a setting in the config file. Durations look like 1h30m, " +
+			"and lists are separated by spaces. The settings are:\n  " +
+			strings.Join(settingsHelp(), "\n  "),
+		Args: cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			path, _, err := config.Path(ConfigPath)
+			if err != nil {
+				return err
+			}
+			return config.SetInFile(path, args[0], args[1])
+		},
+	}
+)
+
+func init() {
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSetCmd)
+}
+
+// settingsHelp lists the settings along with the environment variables
+// overriding them
+func settingsHelp() []string {
+	lines := make([]string, 0)
+	for _, key := range config.Keys() {
+		lines = append(lines, fmt.Sprintf("%-28s %s", key, config.EnvName(key)))
+	}
+	return lines
+}
+
+func showConfig(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	c, err := config.Load(ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		value, err := c.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	}
+
+	return yaml.NewEncoder(os.Stdout).Encode(c)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/internal/metrics"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+	"github.com/miquelruiz/yrs/web"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+)
+
+// shutdownTimeout is how long the web interface waits for the requests in
+// flight when shutting down
+const shutdownTimeout = 5 * time.Second
+
+// server is one of the HTTP servers of the daemon, like the web interface
+type server interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Update the subscriptions periodically and process the download queue",
+	Long: "Update the subscriptions every update_interval, pruning them afterwards " +
+		"if retention.after_update is set, download the videos in the download " +
+		"queue and send the digests every digest.interval. With --web, the web " +
+		"interface is served from the same process, receiving the new videos " +
+		"pushed through WebSub when websub.callback_url is set, and the metrics " +
+		"under /metrics. With --metrics-address, the metrics are served on their own.",
+	Args: cobra.NoArgs,
+	RunE: daemon,
+}
+
+func init() {
+	daemonCmd.Flags().Bool("web", false, "Serve the web interface too")
+	daemonCmd.Flags().String("address", "", "Address to bind to, instead of web.address")
+	daemonCmd.Flags().Int("port", 0, "Port to bind to, instead of web.port")
+	daemonCmd.Flags().String("root-url", "", "Root of the URL where the web interface will be served, instead of web.root_url")
+	daemonCmd.Flags().String("pid-file", "", "File to write the PID to, refusing to start if another daemon holds it")
+	daemonCmd.Flags().String("log-format", "text", "Format of the logs: text or json")
+	daemonCmd.Flags().String("metrics-address", "", "Address to serve the metrics on, like localhost:9090")
+}
+
+func daemon(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	c := cmd.Context().Value(ConfigKey).(*config.Config)
+	serveWeb, _ := cmd.Flags().GetBool("web")
+	pidFile, _ := cmd.Flags().GetString("pid-file")
+	format, _ := cmd.Flags().GetString("log-format")
+	metricsAddress, _ := cmd.Flags().GetString("metrics-address")
+
+	logger, err := newLogger(format)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	slog.SetDefault(logger)
+
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			return err
+		}
+		defer os.Remove(pidFile)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var m *metrics.Metrics
+	if serveWeb || metricsAddress != "" {
+		m = metrics.New(y)
+	}
+
+	servers := make([]server, 0, 2)
+	var push *yrs.WebSub
+	serveErr := make(chan error, 2)
+	serve := func(srv server, addr, what string) {
+		servers = append(servers, srv)
+		go func() {
+			slog.Info("serving the "+what, "address", addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("serving the %s: %w", what, err)
+			}
+		}()
+	}
+	if serveWeb {
+		srv := webServer(cmd, y, c, m)
+		serve(srv, srv.Addr, "web interface")
+		push = webSub(c)
+	}
+	if metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", m)
+		serve(&http.Server{Addr: metricsAddress, Handler: mux}, metricsAddress, "metrics")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		schedule(ctx, y, c, push)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		slog.Error("server failed", "err", err)
+	}
+
+	// From here on, a second signal kills the daemon right away
+	stop()
+	cancel()
+	slog.Info("shutting down, waiting for the running tasks")
+
+	if len(servers) > 0 {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		for _, srv := range servers {
+			if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+				err = errors.Join(err, shutdownErr)
+			}
+		}
+	}
+	<-done
+
+	slog.Info("done")
+	return err
+}
+
+func newLogger(format string) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %s", format)
+	}
+}
+
+// webServer builds the server of the web interface, with the flags taking
+// precedence over the config file, serving m too
+func webServer(cmd *cobra.Command, y *yrs.Yrs, c *config.Config, m *metrics.Metrics) *web.Server {
+	address, _ := cmd.Flags().GetString("address")
+	port, _ := cmd.Flags().GetInt("port")
+	rootUrl, _ := cmd.Flags().GetString("root-url")
+	if address == "" {
+		address = c.Web.Address
+	}
+	if port == 0 {
+		port = c.Web.Port
+	}
+	if rootUrl == "" {
+		rootUrl = c.Web.RootUrl
+	}
+
+	// The debug mode of gin prints every route on startup, which doesn't
+	// mix well with the logs of the daemon
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	return web.NewServer(y, net.JoinHostPort(address, strconv.Itoa(port)), rootUrl, m)
+}
+
+// webSub returns the settings of the WebSub subscriptions, or nil when the
+// web interface can't receive them
+func webSub(c *config.Config) *yrs.WebSub {
+	if c.WebSub.CallbackURL == "" {
+		return nil
+	}
+	return &yrs.WebSub{
+		Hub:      c.WebSub.Hub,
+		Callback: strings.TrimSuffix(c.WebSub.CallbackURL, "/") + "/websub",
+		Lease:    c.WebSub.Lease,
+	}
+}
+
+// schedule runs the periodic tasks right away and then every update
+// interval, until ctx is done. Tasks already running are never interrupted.
+// With push, the WebSub subscriptions are renewed along with them.
+func schedule(ctx context.Context, y *yrs.Yrs, c *config.Config, push *yrs.WebSub) {
+	slog.Info("starting the scheduler", "interval", c.UpdateInterval)
+	ticker := time.NewTicker(c.UpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		runTasks(y, c, push)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runTasks(y *yrs.Yrs, c *config.Config, push *yrs.WebSub) {
+	start := time.Now()
+	videos, err := y.Update()
+	// The videos of the rest were recorded anyway, but the update still
+	// failed for some channels
+	updateFailed := errors.Is(err, yrs.ErrChannelsFailed)
+	if updateFailed {
+		slog.Error("some channels failed", "err", err)
+		err = nil
+	} else if errors.Is(err, yrs.ErrNotification) {
+		slog.Error("notification failed", "err", err)
+		err = nil
+	}
+	if errors.Is(err, yrs.ErrUpdateInProgress) {
+		slog.Warn("update skipped", "err", err)
+	} else if err != nil {
+		slog.Error("update failed", "err", err)
+	} else {
+		slog.Info("updated", "new_videos", len(videos), "duration", time.Since(start))
+	}
+
+	// Pruning after a failed update, even if only for some channels, could
+	// delete the videos the update didn't get to replace
+	if err == nil && !updateFailed && c.Retention.AfterUpdate {
+		pruned, err := y.Prune(retentionPolicy(c), false)
+		if err != nil {
+			slog.Error("prune failed", "err", err)
+		} else {
+			slog.Info("pruned", "videos", len(pruned))
+		}
+	}
+
+	// Updates keep running as a fallback for the pushes the hub fails to
+	// deliver. Subscriptions are renewed when they'd expire before the next
+	// couple of runs.
+	if push != nil {
+		if err := y.RenewWebSub(*push, 2*c.UpdateInterval); err != nil {
+			slog.Error("WebSub renewal failed", "err", err)
+		}
+	}
+
+	downloaded, err := y.ProcessDownloadQueue(c.DownloadDir)
+	for _, v := range downloaded {
+		slog.Info("downloaded", "id", v.ID, "title", v.Title, "path", v.Path)
+	}
+	if err != nil {
+		slog.Error("download failed", "err", err)
+	}
+
+	if c.Digest.Interval > 0 {
+		sendDueDigest(y, c)
+	}
+}
+
+// writePIDFile creates path with the PID of this process, failing if it
+// belongs to another one still running. Files left behind by daemons that
+// didn't exit cleanly are taken over.
+func writePIDFile(path string) error {
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if err = errors.Join(err, f.Close()); err != nil {
+				os.Remove(path)
+			}
+			return err
+		}
+		if !errors.Is(err, fs.ErrExist) || attempt > 0 {
+			return fmt.Errorf("couldn't create the PID file: %w", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("couldn't read the PID file: %w", err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err == nil && running(pid) {
+			return fmt.Errorf("already running with PID %d, according to %s", pid, path)
+		}
+
+		slog.Warn("taking over a stale PID file", "path", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("couldn't remove the stale PID file: %w", err)
+		}
+	}
+}
+
+// running tells whether there's a process with the given PID. Containers
+// reuse PIDs on every restart, so this process doesn't count.
+func running(pid int) bool {
+	if pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Email a digest of the unwatched videos found since the last one",
+	Long: "Email a digest of the unwatched videos found since the last one, " +
+		"through the SMTP server in the digest section of the config file. " +
+		"With digest.interval set, the daemon sends them on its own.",
+	Args: cobra.NoArgs,
+	RunE: digest,
+}
+
+func init() {
+	digestCmd.Flags().Bool("dry-run", false, "Print the digest instead of sending it")
+	digestCmd.Flags().Bool("html", false, "With --dry-run, print the HTML version of the digest")
+}
+
+// digestMailer builds the SMTP client sending the digests
+func digestMailer(c *config.Config) *yrs.SMTPNotifier {
+	return &yrs.SMTPNotifier{
+		Address:  c.Digest.Address,
+		Username: c.Digest.Username,
+		Password: c.Digest.Password,
+		From:     c.Digest.From,
+		To:       c.Digest.To,
+	}
+}
+
+// digestSince is where the first digest starts, as there's no previous one
+func digestSince(c *config.Config) time.Time {
+	if c.Digest.Interval > 0 {
+		return time.Now().Add(-c.Digest.Interval)
+	}
+	return time.Now().Add(-24 * time.Hour)
+}
+
+func digest(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	c := cmd.Context().Value(ConfigKey).(*config.Config)
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	html, _ := cmd.Flags().GetBool("html")
+
+	if html && !dryRun {
+		return errors.New("--html only applies to --dry-run")
+	}
+	cmd.SilenceUsage = true
+
+	templates, err := yrs.ParseDigestTemplates(c.Digest.HTMLTemplate, c.Digest.TextTemplate)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		d, err := y.NewDigest(digestSince(c))
+		if err != nil {
+			return err
+		}
+		htmlBody, textBody, err := d.Render(templates)
+		if err != nil {
+			return err
+		}
+		if html {
+			fmt.Print(htmlBody)
+		} else {
+			fmt.Print(textBody)
+		}
+		return nil
+	}
+
+	if c.Digest.Address == "" || c.Digest.From == "" || len(c.Digest.To) == 0 {
+		return errors.New("digest.address, digest.from and digest.to are needed to send digests")
+	}
+
+	d, err := y.SendDigest(digestMailer(c), templates, digestSince(c))
+	if err != nil {
+		return err
+	}
+	if d.Count == 0 {
+		cmd.PrintErrf("No new videos since %s\n", d.Since.Local().Format(time.DateTime))
+	} else {
+		cmd.PrintErrf("Sent a digest of %d videos\n", d.Count)
+	}
+	return nil
+}
+
+// sendDueDigest sends a digest once digest.interval has passed since the last
+// one
+func sendDueDigest(y *yrs.Yrs, c *config.Config) {
+	last, err := y.LastDigest()
+	if err != nil {
+		slog.Error("digest failed", "err", err)
+		return
+	}
+	if !last.IsZero() && time.Since(last) < c.Digest.Interval {
+		return
+	}
+
+	templates, err := yrs.ParseDigestTemplates(c.Digest.HTMLTemplate, c.Digest.TextTemplate)
+	if err != nil {
+		slog.Error("digest failed", "err", err)
+		return
+	}
+
+	d, err := y.SendDigest(digestMailer(c), templates, digestSince(c))
+	if errors.Is(err, yrs.ErrDigestInProgress) {
+		slog.Warn("digest skipped", "err", err)
+	} else if err != nil {
+		slog.Error("digest failed", "err", err)
+	} else {
+		slog.Info("digest sent", "videos", d.Count, "since", d.Since)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+// feedChecks is how many feeds are checked at the same time
+const feedChecks = 8
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup of yrs, suggesting how to fix the problems found",
+	Long: "Check the config file, the database and its migrations, the build of " +
+		"yrs, the search index, the feeds of the channels and the downloader, " +
+		"suggesting how to fix the problems found. With --fix, pending " +
+		"migrations are applied, the search index is rebuilt and orphaned rows " +
+		"are deleted.",
+	Args: cobra.NoArgs,
+	RunE: doctor,
+}
+
+func init() {
+	doctorCmd.Flags().Bool("fix", false, "Fix the problems in the database")
+	doctorCmd.Flags().Bool("skip-feeds", false, "Don't fetch the feeds of the channels")
+}
+
+// checkup prints the results of the checks as they're made
+type checkup struct {
+	out    io.Writer
+	failed int
+}
+
+func (c *checkup) ok(check, detail string) {
+	fmt.Fprintf(c.out, "ok    %s: %s\n", check, detail)
+}
+
+func (c *checkup) warn(check, detail, fix string) {
+	c.report("warn", check, detail, fix)
+}
+
+func (c *checkup) fail(check, detail, fix string) {
+	c.failed++
+	c.report("fail", check, detail, fix)
+}
+
+func (c *checkup) report(status, check, detail, fix string) {
+	fmt.Fprintf(c.out, "%-5s %s: %s\n", status, check, detail)
+	if fix != "" {
+		fmt.Fprintf(c.out, "      fix: %s\n", fix)
+	}
+}
+
+func doctor(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	fix, _ := cmd.Flags().GetBool("fix")
+	skipFeeds, _ := cmd.Flags().GetBool("skip-feeds")
+	ch := &checkup{out: cmd.OutOrStdout()}
+
+	if available, err := yrs.FTS5Available(); err != nil {
+		ch.fail("build", err.Error(), "")
+	} else if !available {
+		ch.fail(
+			"build", "SQLite was built without FTS5, which the search index needs",
+			"build yrs with the fts5 tag, like go install --tags fts5 github.com/miquelruiz/yrs/cmd/yrs@latest",
+		)
+	} else {
+		ch.ok("build", "SQLite has FTS5")
+	}
+
+	c, ok := checkConfig(ch)
+	if ok {
+		if y, ok := checkDatabase(ch, c, fix); ok {
+			checkSearchIndex(ch, y, fix)
+			checkOrphans(ch, y, fix)
+			checkChannels(ch, y, !skipFeeds)
+			checkDownloader(ch, y)
+		}
+	}
+
+	if ch.failed > 0 {
+		return fmt.Errorf("%d checks failed", ch.failed)
+	}
+	return nil
+}
+
+func checkConfig(ch *checkup) (*config.Config, bool) {
+	path, _, err := config.Path(ConfigPath)
+	if err != nil {
+		ch.fail("config", err.Error(), "give the config file with --config")
+		return nil, false
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		ch.fail("config", err.Error(), fmt.Sprintf("edit %s, or change the setting with yrs config set", path))
+		return nil, false
+	}
+	ch.ok("config", path)
+	return c, true
+}
+
+// checkDatabase opens the database, unless migrations are pending and they
+// aren't to be applied
+func checkDatabase(ch *checkup, c *config.Config, fix bool) (*yrs.Yrs, bool) {
+	if path, ok := databaseFile(c); ok {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			ch.warn(
+				"database", path+" doesn't exist yet",
+				"check database_url if you expected it, or run yrs update to create it",
+			)
+			return nil, false
+		}
+	}
+
+	pending, err := yrs.PendingMigrations(c.DatabaseDriver, c.DatabaseUrl)
+	if err != nil {
+		ch.fail("database", err.Error(), "check database_url, and that the database can be read and written")
+		return nil, false
+	}
+	if len(pending) > 0 && !fix {
+		ch.warn(
+			"migrations", fmt.Sprintf("%d pending: %s", len(pending), strings.Join(pending, ", ")),
+			"run yrs doctor --fix, or any other command, to apply them. The checks of the database are skipped until then.",
+		)
+		return nil, false
+	}
+
+	y, err := newApp(c)
+	if err != nil {
+		ch.fail("database", err.Error(), "check database_url, and that the database can be read and written")
+		return nil, false
+	}
+	if len(pending) > 0 {
+		ch.ok("migrations", fmt.Sprintf("applied %s", strings.Join(pending, ", ")))
+	} else {
+		ch.ok("migrations", "every migration is applied")
+	}
+
+	if err := y.Ping(); err != nil {
+		ch.fail("database", err.Error(), "check database_url, and that the database can be read")
+		return nil, false
+	}
+	ch.ok("database", c.DatabaseUrl)
+	return y, true
+}
+
+// databaseFile returns the file of a SQLite database
+func databaseFile(c *config.Config) (string, bool) {
+	if c.DatabaseDriver != "sqlite3" {
+		return "", false
+	}
+	path, _, _ := strings.Cut(strings.TrimPrefix(c.DatabaseUrl, "file:"), "?")
+	if path == "" || path == ":memory:" {
+		return "", false
+	}
+	return path, true
+}
+
+func checkSearchIndex(ch *checkup, y *yrs.Yrs, fix bool) {
+	r, err := y.CheckSearchIndex()
+	if err != nil {
+		ch.fail("search index", err.Error(), "")
+		return
+	}
+	if r.Consistent() {
+		ch.ok("search index", "matches the videos")
+		return
+	}
+
+	detail := fmt.Sprintf(
+		"%d videos missing, %d stale and %d outdated entries",
+		r.Missing, r.Stale, r.Outdated,
+	)
+	if !fix {
+		ch.warn("search index", detail, "run yrs doctor --fix to rebuild it")
+		return
+	}
+	if err := y.RebuildSearchIndex(); err != nil {
+		ch.fail("search index", err.Error(), "")
+		return
+	}
+	ch.ok("search index", "rebuilt, there were "+detail)
+}
+
+func checkOrphans(ch *checkup, y *yrs.Yrs, fix bool) {
+	orphans, err := y.OrphanedRows()
+	if err != nil {
+		ch.fail("orphaned rows", err.Error(), "")
+		return
+	}
+	if len(orphans) == 0 {
+		ch.ok("orphaned rows", "none")
+		return
+	}
+
+	found := make([]string, 0, len(orphans))
+	for _, table := range slices.Sorted(maps.Keys(orphans)) {
+		found = append(found, fmt.Sprintf("%d in %s", orphans[table], table))
+	}
+	detail := strings.Join(found, ", ")
+	if !fix {
+		ch.warn("orphaned rows", detail, "run yrs doctor --fix to delete them")
+		return
+	}
+	if _, err := y.DeleteOrphanedRows(); err != nil {
+		ch.fail("orphaned rows", err.Error(), "")
+		return
+	}
+	ch.ok("orphaned rows", "deleted "+detail)
+}
+
+// checkChannels reports the channels failing or disabled by the updates, and
+// fetches the feeds of the rest when asked to
+func checkChannels(ch *checkup, y *yrs.Yrs, fetch bool) {
+	channels, err := y.GetChannels()
+	if err != nil {
+		ch.fail("channels", err.Error(), "")
+		return
+	}
+
+	for _, c := range channels {
+		switch {
+		case c.Disabled:
+			ch.warn(
+				"channel "+c.Name, fmt.Sprintf("disabled after %d failed updates: %s", c.Failures, c.LastError),
+				fmt.Sprintf("yrs channel-options %s --enabled once the feed works, or yrs unsubscribe %s", c.ID, c.ID),
+			)
+		case c.Failures > 0:
+			ch.warn(
+				"channel "+c.Name, fmt.Sprintf("the last %d updates failed: %s", c.Failures, c.LastError),
+				fmt.Sprintf("check %s, or yrs unsubscribe %s if it's gone", c.RSS, c.ID),
+			)
+		}
+	}
+	if !fetch {
+		return
+	}
+
+	var (
+		wg      sync.WaitGroup
+		limit   = make(chan struct{}, feedChecks)
+		results = make([]error, len(channels))
+	)
+	for i, c := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			results[i] = y.CheckFeed(c)
+		}()
+	}
+	wg.Wait()
+
+	reachable := 0
+	for i, c := range channels {
+		if err := results[i]; err != nil {
+			ch.fail(
+				"feed of "+c.Name, err.Error(),
+				fmt.Sprintf("check %s, or yrs unsubscribe %s if it's gone", c.RSS, c.ID),
+			)
+			continue
+		}
+		reachable++
+	}
+	if reachable > 0 {
+		ch.ok("feeds", fmt.Sprintf("%d of %d reachable", reachable, len(channels)))
+	}
+}
+
+func checkDownloader(ch *checkup, y *yrs.Yrs) {
+	version, err := y.CheckDownloader()
+	if err != nil {
+		ch.warn(
+			"downloader", err.Error(),
+			"install yt-dlp, see https://github.com/yt-dlp/yt-dlp#installation, to download from YouTube and most sites",
+		)
+		return
+	}
+	ch.ok("downloader", yrs.DefaultDownloader+" "+version)
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		ch.warn(
+			"ffmpeg", "not found",
+			"install ffmpeg, which yt-dlp needs to merge the best video and audio formats",
+		)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+)
+
+// defaultHookRetries is how many times failed requests of the hooks are
+// retried, unless configured otherwise
+const defaultHookRetries = 3
+
+// hookQueue is how many events wait for an HTTP hook busy retrying a
+// delivery, before dropping the new ones
+const hookQueue = 100
+
+// eventBus subscribes the hooks configured in the config file to the events of
+// yrs, logging their failures
+func eventBus(c *config.Config) *yrs.EventBus {
+	onError := func(e yrs.Event, err error) {
+		slog.Error("hook failed", "event", e.Type, "err", err)
+	}
+	bus := &yrs.EventBus{OnError: onError}
+
+	for _, h := range c.Hooks {
+		var hook yrs.Hook
+		if len(h.Command) > 0 {
+			hook = &yrs.ExecHook{Command: h.Command, Timeout: h.Timeout}
+		} else {
+			// Hooks don't go through the client of the feeds, which is
+			// rate limited and retries on its own
+			timeout := h.Timeout
+			if timeout == 0 {
+				timeout = c.HTTP.Timeout
+			}
+			retries := h.MaxRetries
+			if retries == 0 {
+				retries = defaultHookRetries
+			}
+			// Retries back off for a while, which would hold up the
+			// updates and downloads publishing the events
+			hook = yrs.NewQueuedHook(&yrs.HTTPHook{
+				URL:        h.URL,
+				Secret:     h.Secret,
+				MaxRetries: retries,
+				Client:     &http.Client{Timeout: timeout},
+			}, hookQueue, onError)
+		}
+
+		names := h.Events
+		if len(names) == 0 {
+			names = config.HookEvents
+		}
+		events := make([]yrs.EventType, 0, len(names))
+		for _, e := range names {
+			events = append(events, yrs.EventType(e))
+		}
+		bus.Subscribe(hook, events...)
+	}
+	return bus
+}
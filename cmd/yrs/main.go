@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"text/tabwriter"
+	"strings"
+	"time"
 
 	"github.com/miquelruiz/yrs/internal/config"
 	"github.com/miquelruiz/yrs/internal/vcs"
@@ -14,14 +14,13 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
-	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 )
 
 type KeyType int
 
 const (
 	AppKey KeyType = iota
+	ConfigKey
 )
 
 var (
@@ -29,23 +28,27 @@ var (
 	rootCmd    = &cobra.Command{
 		Use:   "yrs",
 		Short: "YouTube RSS Subscriber",
-		Long:  "A tool to subscribe to YouTube channels without a YouTube account",
+		Long: "A tool to subscribe to YouTube channels without a YouTube account, " +
+			"as well as to PeerTube channels and any other RSS or Atom media feed",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if skipsSetup(cmd) {
+				return nil
+			}
+
 			c, err := config.Load(ConfigPath)
 			if err != nil {
-				return fmt.Errorf(
-					"couldn't load the config file %s: %w",
-					ConfigPath,
-					err,
-				)
+				return fmt.Errorf("couldn't load the config file: %w", err)
 			}
 
-			db, err := yrs.New(c.DatabaseDriver, c.DatabaseUrl)
+			db, err := newApp(c)
 			if err != nil {
 				return fmt.Errorf("couldn't create schema: %w", err)
 			}
+			// Lets the hooks finish with the events of the command
+			cobra.OnFinalize(func() { db.Close() })
 
 			ctx := context.WithValue(cmd.Context(), AppKey, db)
+			ctx = context.WithValue(ctx, ConfigKey, c)
 			cmd.SetContext(ctx)
 
 			return nil
@@ -59,12 +62,22 @@ var (
 	}
 
 	subscribeYouTubeCmd = &cobra.Command{
-		Use:   "subscribe-yt <YouTube channel URL>",
+		Use:   "subscribe-yt <YouTube channel URL, handle, video URL or ID>",
 		Short: "Subscribe to the given channel",
 		Args:  cobra.ExactArgs(1),
 		RunE:  subscribeYouTube,
 	}
 
+	subscribePlaylistCmd = &cobra.Command{
+		Use:   "subscribe-playlist <YouTube playlist URL or ID>",
+		Short: "Subscribe to the given YouTube playlist",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			return yrs.SubscribeYouTubePlaylist(args[0])
+		},
+	}
+
 	subscribeCmd = &cobra.Command{
 		Use:   "subscribe <rss url>",
 		Short: "Subscribe to the given feed",
@@ -73,25 +86,50 @@ var (
 	}
 
 	listVideosCmd = &cobra.Command{
-		Use:   "list-videos",
-		Short: "List all the videos in the database",
+		Use:   "list-videos [Channel name or ID]",
+		Short: "List the videos in the database, optionally only the ones of a channel",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  listVideos,
 	}
 
+	markWatchedCmd = &cobra.Command{
+		Use:   "mark-watched <Video ID>...",
+		Short: "Mark the given videos as watched",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			unwatched, _ := cmd.Flags().GetBool("unwatched")
+			return yrs.SetWatched(!unwatched, args...)
+		},
+	}
+
 	listChannelsCmd = &cobra.Command{
 		Use:   "list-channels",
 		Short: "List all the subscribed channels",
 		RunE:  listChannels,
 	}
 
-	unsubscribeCmd = &cobra.Command{
-		Use:   "unsubscribe <Channel ID>",
-		Short: "Unsubscribe from the given channel",
+	channelOptionsCmd = &cobra.Command{
+		Use:   "channel-options <Channel ID>",
+		Short: "Choose whether Shorts and live streams are recorded for a channel, and whether it's updated at all",
 		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-			return yrs.Unsubscribe(args[0])
-		},
+		RunE:  channelOptions,
+	}
+
+	pruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Delete the videos outside of the retention policy",
+		Long: "Delete the videos outside of the retention policy. The policy is " +
+			"taken from the config file, unless overridden by flags. " +
+			"Downloaded videos are always kept.",
+		Args: cobra.NoArgs,
+		RunE: prune,
+	}
+
+	downloadCmd = &cobra.Command{
+		Use:   "download [Video ID]...",
+		Short: "Download the given videos, or the ones in the download queue",
+		RunE:  download,
 	}
 
 	searchCmd = &cobra.Command{
@@ -110,157 +148,262 @@ var (
 	}
 )
 
-func getChannelID(r io.Reader) string {
-	channelID := ""
-	h := html.NewTokenizer(r)
-
-LOOP:
-	for {
-		tt := h.Next()
-		switch {
-		case tt == html.ErrorToken:
-			break LOOP
-		default:
-			t := h.Token()
-			if t.DataAtom != atom.Meta {
-				continue
-			}
+func subscribeYouTube(cmd *cobra.Command, args []string) error {
+	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	return yrs.SubscribeYouTube(args[0])
+}
 
-			attrMap := map[string]string{}
-			for _, a := range t.Attr {
-				attrMap[a.Key] = a.Val
-			}
+func subscribe(cmd *cobra.Command, args []string) error {
+	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	return yrs.Subscribe(args[0])
+}
 
-			if attrMap["itemprop"] == "identifier" ||
-				attrMap["itemprop"] == "channelId" {
-				channelID = attrMap["content"]
-				break LOOP
-			}
+// skipsSetup tells the commands that don't need the database, like the ones
+// managing the config file or generating shell completions, and the ones
+// checking it on their own, like doctor. Completions are requested through a
+// hidden command that runs before the flags of the completed command are
+// parsed, so it opens the database on its own.
+func skipsSetup(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "completion", "config", "doctor", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
 		}
 	}
-
-	return channelID
+	return false
 }
 
-func subscribeYouTube(cmd *cobra.Command, args []string) error {
-	res, err := http.Get(args[0])
+func newApp(c *config.Config) (*yrs.Yrs, error) {
+	client, err := yrs.NewHTTPClient(yrs.HTTPOptions{
+		Timeout:     c.HTTP.Timeout,
+		UserAgent:   c.HTTP.UserAgent,
+		Proxy:       c.HTTP.Proxy,
+		MinInterval: c.HTTP.MinInterval,
+		MaxRetries:  c.HTTP.MaxRetries,
+	})
 	if err != nil {
+		return nil, err
+	}
+	return yrs.New(
+		c.DatabaseDriver,
+		c.DatabaseUrl,
+		yrs.WithHTTPClient(client),
+		yrs.WithNotifiers(notifiers(c)...),
+		yrs.WithEventBus(eventBus(c)),
+		yrs.WithDisableAfter(c.DisableAfterFailures),
+	)
+}
+
+func update(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	// The videos were recorded even if some channels or the notifications
+	// failed
+	videos, updateErr := y.Update()
+	if updateErr != nil && !errors.Is(updateErr, yrs.ErrChannelsFailed) && !errors.Is(updateErr, yrs.ErrNotification) {
+		return updateErr
+	}
+
+	if err := printRecords(cmd, videos, videoColumns); err != nil {
 		return err
 	}
-	defer res.Body.Close()
 
-	channelID := getChannelID(res.Body)
-	if channelID == "" {
-		return fmt.Errorf("channelID not found in %s", args[0])
+	// Pruning after some channels failed could delete the videos the update
+	// didn't get to replace
+	c := cmd.Context().Value(ConfigKey).(*config.Config)
+	if !c.Retention.AfterUpdate || errors.Is(updateErr, yrs.ErrChannelsFailed) {
+		return updateErr
 	}
 
-	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	return yrs.SubscribeYouTubeID(channelID)
+	pruned, err := y.Prune(retentionPolicy(c), false)
+	if err != nil {
+		return errors.Join(updateErr, err)
+	}
+	fmt.Fprintf(os.Stderr, "Pruned %d videos\n", len(pruned))
+
+	return updateErr
 }
 
-func subscribe(cmd *cobra.Command, args []string) error {
-	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	return yrs.Subscribe(args[0])
+func retentionPolicy(c *config.Config) yrs.RetentionPolicy {
+	return yrs.RetentionPolicy{
+		KeepLast: c.Retention.KeepLast,
+		MaxAge:   c.Retention.MaxAge(),
+	}
 }
 
-func update(cmd *cobra.Command, args []string) error {
+func prune(cmd *cobra.Command, args []string) error {
 	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	videos, err := yrs.Update()
+	c := cmd.Context().Value(ConfigKey).(*config.Config)
+
+	policy := retentionPolicy(c)
+	if cmd.Flags().Changed("keep-last") {
+		policy.KeepLast, _ = cmd.Flags().GetInt("keep-last")
+	}
+	if cmd.Flags().Changed("max-age-days") {
+		days, _ := cmd.Flags().GetInt("max-age-days")
+		policy.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	videos, err := yrs.Prune(policy, dryRun)
 	if err != nil {
 		return err
 	}
 
-	for _, v := range videos {
-		fmt.Printf(
-			"Title: %s\nChannel: %s\nURL: %s\n\n",
-			v.Title,
-			v.Channel.Name,
-			v.URL,
-		)
+	if err := printRecords(cmd, videos, videoColumns); err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "Would prune %d videos\n", len(videos))
+	} else {
+		fmt.Fprintf(os.Stderr, "Pruned %d videos\n", len(videos))
 	}
 
 	return nil
 }
 
-func search(cmd *cobra.Command, args []string) error {
+func channelOptions(cmd *cobra.Command, args []string) error {
 	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	results, err := yrs.Search(args[0])
+	c, err := yrs.GetChannel(args[0])
 	if err != nil {
 		return err
 	}
 
-	if len(results) == 0 {
-		return nil
+	shorts, live := c.IncludeShorts, c.IncludeLive
+	if cmd.Flags().Changed("shorts") {
+		shorts, _ = cmd.Flags().GetBool("shorts")
+	}
+	if cmd.Flags().Changed("live") {
+		live, _ = cmd.Flags().GetBool("live")
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 5, 2, 3, ' ', 0)
-	defer w.Flush()
-	fmt.Fprintln(w, "ID\tTitle\tChannel\tURL")
+	if err := yrs.SetContentOptions(c.ID, shorts, live); err != nil {
+		return err
+	}
 
-	for _, r := range results {
-		url := fmt.Sprintf("https://www.youtube.com/watch?v=%s", r.ID)
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Title, r.Channel, url)
+	enabled := !c.Disabled
+	if cmd.Flags().Changed("enabled") {
+		enabled, _ = cmd.Flags().GetBool("enabled")
+		if err := yrs.SetEnabled(c.ID, enabled); err != nil {
+			return err
+		}
 	}
 
+	fmt.Printf("%s: Shorts %t, live streams %t, enabled %t\n", c.Name, shorts, live, enabled)
 	return nil
 }
 
-func listVideos(cmd *cobra.Command, args []string) error {
-	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	videos, err := yrs.GetVideos()
+func download(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	dir, _ := cmd.Flags().GetString("dir")
+	if dir == "" {
+		dir = cmd.Context().Value(ConfigKey).(*config.Config).DownloadDir
+	}
+	queue, _ := cmd.Flags().GetBool("queue")
+
+	if queue {
+		if len(args) == 0 {
+			return errors.New("no videos to queue")
+		}
+		return y.QueueDownload(args...)
+	}
+
+	if len(args) == 0 {
+		videos, err := y.ProcessDownloadQueue(dir)
+		for _, v := range videos {
+			fmt.Printf("Downloaded %s to %s\n", v.Title, v.Path)
+		}
+		return err
+	}
+
+	videos, err := y.GetVideosByID(args)
 	if err != nil {
 		return err
 	}
-	if len(videos) == 0 {
-		return nil
+	if len(videos) != len(args) {
+		return fmt.Errorf("some of the videos were not found: %v", args)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 5, 2, 3, ' ', 0)
-	defer w.Flush()
-	fmt.Fprintln(w, "ID\tTitle\tURL\tPublished\tChannelId")
-
 	for _, v := range videos {
-		fmt.Fprintf(
-			w,
-			"%s\t%s\t%s\t%s\t%s\t\n",
-			v.ID,
-			v.Title,
-			v.URL,
-			v.Published,
-			v.Channel.Name,
-		)
+		p, err := y.Download(v, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Downloaded %s to %s\n", v.Title, p)
 	}
 
 	return nil
 }
 
-func listChannels(cmd *cobra.Command, args []string) error {
+func search(cmd *cobra.Command, args []string) error {
 	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
-	channels, err := yrs.GetChannels()
+	results, err := yrs.Search(args[0])
 	if err != nil {
 		return err
 	}
-	if len(channels) == 0 {
-		return nil
+
+	return printRecords(cmd, results, searchColumns)
+}
+
+func listVideos(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+
+	q := yrs.VideoQuery{}
+	if len(args) > 0 {
+		q.Channel = args[0]
+	}
+	q.Unwatched, _ = cmd.Flags().GetBool("unwatched")
+	q.Downloaded, _ = cmd.Flags().GetBool("downloaded")
+	q.Limit, _ = cmd.Flags().GetInt("limit")
+	q.Sort, _ = cmd.Flags().GetString("sort")
+
+	var err error
+	since, _ := cmd.Flags().GetString("since")
+	if q.Since, err = parseDateFlag(since, false); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	until, _ := cmd.Flags().GetString("until")
+	if q.Until, err = parseDateFlag(until, true); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 5, 2, 3, ' ', 0)
-	defer w.Flush()
-	fmt.Fprintln(w, "#\tID\tName\tURL\tAutodownload")
+	videos, err := y.QueryVideos(q)
+	if err != nil {
+		return err
+	}
+
+	return printRecords(cmd, videos, videoColumns)
+}
 
-	for i, c := range channels {
-		fmt.Fprintf(
-			w,
-			"%d\t%s\t%s\t%s\t%t\t\n",
-			i,
-			c.ID,
-			c.Name,
-			c.URL,
-			c.Autodownload,
-		)
+// parseDateFlag accepts dates, with or without time, in the local timezone,
+// as well as RFC 3339 timestamps. Dates without time refer to the start of the
+// day, or to its end with endOfDay.
+func parseDateFlag(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
 	}
 
-	return nil
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func listChannels(cmd *cobra.Command, args []string) error {
+	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	channels, err := yrs.GetChannels()
+	if err != nil {
+		return err
+	}
+
+	return printRecords(cmd, channels, channelColumns)
 }
 
 func main() {
@@ -272,16 +415,64 @@ func main() {
 		"Path to config file",
 	)
 
+	channelOptionsCmd.Flags().Bool("shorts", true, "Record YouTube Shorts")
+	channelOptionsCmd.Flags().Bool("live", true, "Record live streams")
+	channelOptionsCmd.Flags().Bool("enabled", true, "Update the channel, forgetting its failures")
+
+	pruneCmd.Flags().Bool("dry-run", false, "List the videos without deleting them")
+	pruneCmd.Flags().Int("keep-last", 0, "Number of videos to keep per channel")
+	pruneCmd.Flags().Int("max-age-days", 0, "Delete videos older than this")
+
+	listVideosCmd.Flags().String("since", "", "Only videos published from this date on, like 2024-01-31")
+	listVideosCmd.Flags().String("until", "", "Only videos published up to this date, like 2024-01-31")
+	listVideosCmd.Flags().Int("limit", 0, "Maximum number of videos to list")
+	listVideosCmd.Flags().Bool("unwatched", false, "Only videos not watched yet")
+	listVideosCmd.Flags().Bool("downloaded", false, "Only downloaded videos")
+	listVideosCmd.Flags().String(
+		"sort",
+		yrs.SortOldest,
+		"Sort order: "+strings.Join(yrs.VideoSorts(), ", "),
+	)
+
+	markWatchedCmd.Flags().Bool("unwatched", false, "Mark the videos as not watched instead")
+
+	downloadCmd.Flags().String("dir", "", "Directory to download the videos to, instead of download_dir")
+	downloadCmd.Flags().Bool("queue", false, "Add the videos to the download queue instead")
+
+	listVideosCmd.ValidArgsFunction = completeChannels
+	channelOptionsCmd.ValidArgsFunction = completeChannelIDs
+	markWatchedCmd.ValidArgsFunction = completeVideos
+	downloadCmd.ValidArgsFunction = completeVideos
+
+	for _, cmd := range []*cobra.Command{
+		updateCmd, listVideosCmd, listChannelsCmd, searchCmd, pruneCmd,
+	} {
+		addOutputFlags(cmd)
+	}
+
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(subscribeYouTubeCmd)
+	rootCmd.AddCommand(subscribePlaylistCmd)
 	rootCmd.AddCommand(subscribeCmd)
 	rootCmd.AddCommand(listVideosCmd)
 	rootCmd.AddCommand(listChannelsCmd)
+	rootCmd.AddCommand(markWatchedCmd)
 	rootCmd.AddCommand(unsubscribeCmd)
+	rootCmd.AddCommand(channelOptionsCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(playlistCmd)
+	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(playCmd)
 	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(tuiCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(digestCmd)
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(versionCmd)
 
+	// Cobra already printed the error
 	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
-		panic(err)
+		os.Exit(1)
 	}
 }
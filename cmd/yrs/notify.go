@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+)
+
+// notifiers builds the notifiers configured in the config file
+func notifiers(c *config.Config) []yrs.Notifier {
+	// Notifications don't go through the client of the feeds, so that they
+	// don't wait for its rate limit nor use it up
+	client := &http.Client{Timeout: c.HTTP.Timeout}
+	ns := make([]yrs.Notifier, 0, len(c.Notifiers))
+	for _, n := range c.Notifiers {
+		var notifier yrs.Notifier
+		switch n.Type {
+		case config.NotifierSMTP:
+			notifier = &yrs.SMTPNotifier{
+				Address:  n.Address,
+				Username: n.Username,
+				Password: n.Password,
+				From:     n.From,
+				To:       n.To,
+			}
+		case config.NotifierWebhook:
+			notifier = &yrs.WebhookNotifier{URL: n.URL, Client: client}
+		case config.NotifierNtfy:
+			notifier = &yrs.NtfyNotifier{URL: n.URL, Token: n.Token, Client: client}
+		case config.NotifierGotify:
+			notifier = &yrs.GotifyNotifier{URL: n.URL, Token: n.Token, Client: client}
+		case config.NotifierDesktop:
+			notifier = &yrs.DesktopNotifier{Command: n.Command}
+		default:
+			// Already rejected when validating the config
+			continue
+		}
+
+		if channels := c.NotifiedChannels(n); channels != nil {
+			notifier = yrs.FilterChannels(notifier, channels...)
+		}
+		ns = append(ns, notifier)
+	}
+	return ns
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var outputFormats = []string{"table", "json", "jsonl", "csv", "tsv"}
+
+// column describes one of the fields printed by the list commands. Name is
+// the CSV/TSV header, matching the JSON field name where possible, while
+// header is the one shown in tables.
+type column[T any] struct {
+	name   string
+	header string
+	value  func(T) string
+}
+
+var videoColumns = []column[yrs.Video]{
+	{"id", "ID", func(v yrs.Video) string { return v.ID }},
+	{"title", "Title", func(v yrs.Video) string { return v.Title }},
+	{"url", "URL", func(v yrs.Video) string { return v.URL }},
+	{"published", "Published", func(v yrs.Video) string { return v.Published.Format(time.DateTime) }},
+	{"channel_name", "Channel", func(v yrs.Video) string { return v.Channel.Name }},
+	{"downloaded", "Downloaded", func(v yrs.Video) string { return strconv.FormatBool(v.Downloaded) }},
+	{"watched", "Watched", func(v yrs.Video) string { return strconv.FormatBool(v.Watched) }},
+}
+
+var channelColumns = []column[yrs.Channel]{
+	{"id", "ID", func(c yrs.Channel) string { return c.ID }},
+	{"name", "Name", func(c yrs.Channel) string { return c.Name }},
+	{"url", "URL", func(c yrs.Channel) string { return c.URL }},
+	{"provider", "Provider", func(c yrs.Channel) string { return c.Provider }},
+	{"kind", "Kind", func(c yrs.Channel) string { return c.Kind }},
+	{"include_shorts", "Shorts", func(c yrs.Channel) string { return strconv.FormatBool(c.IncludeShorts) }},
+	{"include_live", "Live", func(c yrs.Channel) string { return strconv.FormatBool(c.IncludeLive) }},
+	{"autodownload", "Autodownload", func(c yrs.Channel) string { return strconv.FormatBool(c.Autodownload) }},
+	{"failures", "Failures", func(c yrs.Channel) string { return strconv.Itoa(c.Failures) }},
+	{"disabled", "Disabled", func(c yrs.Channel) string { return strconv.FormatBool(c.Disabled) }},
+}
+
+var searchColumns = []column[yrs.SearchResult]{
+	{"id", "ID", func(r yrs.SearchResult) string { return r.ID }},
+	{"title", "Title", func(r yrs.SearchResult) string { return r.Title }},
+	{"channel", "Channel", func(r yrs.SearchResult) string { return r.Channel }},
+	{"url", "URL", func(r yrs.SearchResult) string { return r.URL }},
+}
+
+var playlistColumns = []column[yrs.Playlist]{
+	{"name", "Name", func(p yrs.Playlist) string { return p.Name }},
+	{"videos", "Videos", func(p yrs.Playlist) string { return strconv.Itoa(p.Videos) }},
+	{"autodownload", "Autodownload", func(p yrs.Playlist) string { return strconv.FormatBool(p.Autodownload) }},
+}
+
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(
+		"output",
+		"o",
+		"table",
+		"Output format: "+strings.Join(outputFormats, ", "),
+	)
+	cmd.Flags().String(
+		"format",
+		"",
+		"Go template applied to each record, like '{{ .Title }}'. Overrides --output",
+	)
+}
+
+// printRecords writes the records to stdout in the format chosen through the
+// flags added by addOutputFlags
+func printRecords[T any](cmd *cobra.Command, records []T, columns []column[T]) error {
+	w := os.Stdout
+	output, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+
+	if format != "" {
+		tmpl, err := template.New("format").Parse(format)
+		if err != nil {
+			return fmt.Errorf("invalid format: %w", err)
+		}
+		for _, r := range records {
+			if err := tmpl.Execute(w, r); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	switch output {
+	case "table":
+		if len(records) == 0 {
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 5, 2, 3, ' ', 0)
+		headers := make([]string, 0, len(columns))
+		for _, c := range columns {
+			headers = append(headers, c.header)
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		for _, r := range records {
+			fmt.Fprintln(tw, strings.Join(rowValues(r, columns), "\t"))
+		}
+		return tw.Flush()
+
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []T{}
+		}
+		return enc.Encode(records)
+
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if output == "tsv" {
+			cw.Comma = '\t'
+		}
+		headers := make([]string, 0, len(columns))
+		for _, c := range columns {
+			headers = append(headers, c.name)
+		}
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := cw.Write(rowValues(r, columns)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return fmt.Errorf(
+			"unknown output format %s, expected one of: %s",
+			output,
+			strings.Join(outputFormats, ", "),
+		)
+	}
+}
+
+func rowValues[T any](r T, columns []column[T]) []string {
+	values := make([]string, 0, len(columns))
+	for _, c := range columns {
+		values = append(values, c.value(r))
+	}
+	return values
+}
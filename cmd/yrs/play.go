@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var playCmd = &cobra.Command{
+	Use:   "play [Video ID]",
+	Short: "Open a video in the configured player and mark it as watched",
+	Long: "Open a video in the player configured in the config file, mpv by " +
+		"default, and mark it as watched. Downloaded videos are played from " +
+		"their file.",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeVideo,
+	RunE:              play,
+}
+
+func init() {
+	playCmd.Flags().Bool("next-unwatched", false, "Play the oldest video not watched yet")
+	playCmd.Flags().String("channel", "", "With --next-unwatched, only consider this channel")
+	playCmd.RegisterFlagCompletionFunc("channel", completeChannels)
+}
+
+// player builds the yrs.Player out of the config file
+func player(c *config.Config) yrs.Player {
+	return yrs.Player{
+		Command:          c.Player.Command,
+		WatchedOnSuccess: c.Player.WatchedOnSuccess,
+	}
+}
+
+func play(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	c := cmd.Context().Value(ConfigKey).(*config.Config)
+	next, _ := cmd.Flags().GetBool("next-unwatched")
+	channel, _ := cmd.Flags().GetString("channel")
+
+	if next == (len(args) == 1) || (channel != "" && !next) {
+		return errors.New("either a video ID or --next-unwatched is needed")
+	}
+	cmd.SilenceUsage = true
+
+	var v *yrs.Video
+	if next {
+		var err error
+		if v, err = y.NextUnwatched(channel); err != nil {
+			return err
+		}
+	} else {
+		videos, err := y.GetVideosByID(args)
+		if err != nil {
+			return err
+		}
+		if len(videos) == 0 {
+			return fmt.Errorf("video %s not found", args[0])
+		}
+		v = &videos[0]
+	}
+
+	cmd.PrintErrf("Playing %s\n", v.Title)
+	return y.Play(*v, player(c))
+}
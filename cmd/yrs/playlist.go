@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	playlistCmd = &cobra.Command{
+		Use:   "playlist",
+		Short: "Manage local playlists, like " + yrs.WatchLater,
+	}
+
+	playlistCreateCmd = &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a playlist",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			autodownload, _ := cmd.Flags().GetBool("autodownload")
+			return yrs.CreatePlaylist(args[0], autodownload)
+		},
+	}
+
+	playlistDeleteCmd = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a playlist. The videos in it are kept",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			return yrs.DeletePlaylist(args[0])
+		},
+	}
+
+	playlistAutodownloadCmd = &cobra.Command{
+		Use:   "autodownload <name> <true|false>",
+		Short: "Choose whether videos added to the playlist are downloaded",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			autodownload, err := strconv.ParseBool(args[1])
+			if err != nil {
+				return err
+			}
+			return yrs.SetPlaylistAutodownload(args[0], autodownload)
+		},
+	}
+
+	playlistListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the playlists",
+		Args:  cobra.NoArgs,
+		RunE:  listPlaylists,
+	}
+
+	playlistShowCmd = &cobra.Command{
+		Use:   "show <name>",
+		Short: "List the videos in a playlist",
+		Args:  cobra.ExactArgs(1),
+		RunE:  showPlaylist,
+	}
+
+	playlistAddCmd = &cobra.Command{
+		Use:   "add <name> <Video ID>...",
+		Short: "Add videos to the end of a playlist",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			return yrs.AddToPlaylist(args[0], args[1:]...)
+		},
+	}
+
+	playlistRemoveCmd = &cobra.Command{
+		Use:   "remove <name> <Video ID>",
+		Short: "Remove a video from a playlist",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			return yrs.RemoveFromPlaylist(args[0], args[1])
+		},
+	}
+
+	playlistMoveCmd = &cobra.Command{
+		Use:   "move <name> <Video ID> <position>",
+		Short: "Move a video to another position of a playlist, starting at 1",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+			position, err := strconv.Atoi(args[2])
+			if err != nil {
+				return err
+			}
+			return yrs.MoveInPlaylist(args[0], args[1], position)
+		},
+	}
+
+	playlistExportCmd = &cobra.Command{
+		Use:   "export <name>",
+		Short: "Print a playlist as an Atom feed or as M3U",
+		Args:  cobra.ExactArgs(1),
+		RunE:  exportPlaylist,
+	}
+)
+
+func init() {
+	playlistCreateCmd.Flags().Bool("autodownload", false, "Download the videos added to the playlist")
+	for _, cmd := range []*cobra.Command{
+		playlistDeleteCmd, playlistAutodownloadCmd, playlistShowCmd,
+		playlistRemoveCmd, playlistMoveCmd, playlistExportCmd,
+	} {
+		cmd.ValidArgsFunction = completePlaylist
+	}
+	playlistAddCmd.ValidArgsFunction = completePlaylistVideos
+	playlistExportCmd.Flags().String("format", "m3u", "Export format: m3u or atom")
+	addOutputFlags(playlistListCmd)
+	addOutputFlags(playlistShowCmd)
+
+	playlistCmd.AddCommand(playlistCreateCmd)
+	playlistCmd.AddCommand(playlistDeleteCmd)
+	playlistCmd.AddCommand(playlistAutodownloadCmd)
+	playlistCmd.AddCommand(playlistListCmd)
+	playlistCmd.AddCommand(playlistShowCmd)
+	playlistCmd.AddCommand(playlistAddCmd)
+	playlistCmd.AddCommand(playlistRemoveCmd)
+	playlistCmd.AddCommand(playlistMoveCmd)
+	playlistCmd.AddCommand(playlistExportCmd)
+}
+
+func listPlaylists(cmd *cobra.Command, args []string) error {
+	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	playlists, err := yrs.GetPlaylists()
+	if err != nil {
+		return err
+	}
+
+	return printRecords(cmd, playlists, playlistColumns)
+}
+
+func showPlaylist(cmd *cobra.Command, args []string) error {
+	yrs := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	videos, err := yrs.GetPlaylistVideos(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printRecords(cmd, videos, videoColumns)
+}
+
+func exportPlaylist(cmd *cobra.Command, args []string) error {
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	videos, err := y.GetPlaylistVideos(args[0])
+	if err != nil {
+		return err
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	switch format {
+	case "m3u":
+		return yrs.WriteM3U(os.Stdout, videos)
+	case "atom":
+		fmt.Print(xml.Header)
+		enc := xml.NewEncoder(os.Stdout)
+		enc.Indent("", "  ")
+		return enc.Encode(yrs.NewAtomFeed(args[0], "yrs:playlist:"+args[0], videos))
+	default:
+		return fmt.Errorf("unknown export format %s", format)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/miquelruiz/yrs/internal/config"
+	"github.com/miquelruiz/yrs/internal/tui"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse, search and open videos in a full-screen terminal interface",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+		c := cmd.Context().Value(ConfigKey).(*config.Config)
+		return tui.Run(y, func(v yrs.Video) error {
+			return y.Play(v, player(c))
+		})
+	},
+}
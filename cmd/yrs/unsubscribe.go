@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/spf13/cobra"
+)
+
+var unsubscribeCmd = &cobra.Command{
+	Use:   "unsubscribe <Channel name, ID or URL>",
+	Short: "Unsubscribe from the given channel, deleting its videos",
+	Long: "Unsubscribe from the given channel, deleting its videos. The channel " +
+		"can be given by name, ID, URL or the beginning of any of the first two. " +
+		"Downloaded files are kept.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeChannels,
+	RunE:              unsubscribe,
+}
+
+func init() {
+	unsubscribeCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+}
+
+func unsubscribe(cmd *cobra.Command, args []string) error {
+	// The arguments are fine by now, errors from here on aren't about usage
+	cmd.SilenceUsage = true
+
+	y := cmd.Context().Value(AppKey).(*yrs.Yrs)
+	yes, _ := cmd.Flags().GetBool("yes")
+	in := bufio.NewReader(cmd.InOrStdin())
+	out := cmd.ErrOrStderr()
+
+	channels, err := y.FindChannels(args[0])
+	if err != nil {
+		return err
+	}
+
+	var channel yrs.Channel
+	switch {
+	case len(channels) == 0:
+		return fmt.Errorf("no channel matches %q", args[0])
+	case len(channels) == 1:
+		channel = channels[0]
+	case yes:
+		return fmt.Errorf("%d channels match %q, be more specific", len(channels), args[0])
+	default:
+		fmt.Fprintf(out, "%d channels match %q:\n", len(channels), args[0])
+		for i, c := range channels {
+			fmt.Fprintf(out, "  %d) %s (%s)\n", i+1, c.Name, c.URL)
+		}
+		answer, err := prompt(in, out, fmt.Sprintf("Which one? [1-%d] ", len(channels)))
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(channels) {
+			return fmt.Errorf("invalid choice %q", answer)
+		}
+		channel = channels[n-1]
+	}
+
+	videos, err := y.QueryVideos(yrs.VideoQuery{Channel: channel.ID})
+	if err != nil {
+		return err
+	}
+	downloaded := 0
+	for _, v := range videos {
+		if v.Downloaded {
+			downloaded++
+		}
+	}
+
+	fmt.Fprintf(
+		out,
+		"Unsubscribing from %s (%s) removes %d videos from the database.",
+		channel.Name,
+		channel.URL,
+		len(videos),
+	)
+	if downloaded > 0 {
+		fmt.Fprintf(out, " The files of the %d downloaded ones are kept.", downloaded)
+	}
+	fmt.Fprintln(out)
+
+	if !yes {
+		answer, err := prompt(in, out, "Continue? [y/N] ")
+		if err != nil {
+			return err
+		}
+		if a := strings.ToLower(answer); a != "y" && a != "yes" {
+			return errors.New("aborted")
+		}
+	}
+
+	return y.Unsubscribe(channel.ID)
+}
+
+// prompt asks a question and returns the answer, without surrounding spaces.
+// No answer at all, like when there's nothing to read, is an empty string.
+func prompt(in *bufio.Reader, out io.Writer, question string) (string, error) {
+	fmt.Fprint(out, question)
+	answer, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(out)
+	}
+	return strings.TrimSpace(answer), nil
+}
@@ -4,7 +4,7 @@ CREATE TABLE channels (
 	url VARCHAR(256) NOT NULL,
 	name VARCHAR(64) NOT NULL,
 	rss VARCHAR(256) NOT NULL,
-	autodownload INTEGER NOT NULL,
+	autodownload INTEGER NOT NULL, provider VARCHAR(32) NOT NULL DEFAULT 'rss', kind VARCHAR(16) NOT NULL DEFAULT 'channel', include_shorts INTEGER NOT NULL DEFAULT 1, include_live INTEGER NOT NULL DEFAULT 1, failures INTEGER NOT NULL DEFAULT 0, last_error TEXT NOT NULL DEFAULT '', last_success DATETIME, disabled INTEGER NOT NULL DEFAULT 0,
 	PRIMARY KEY (id)
 );
 CREATE TABLE videos (
@@ -13,7 +13,7 @@ CREATE TABLE videos (
 	title VARCHAR(256) NOT NULL,
 	published DATETIME NOT NULL,
 	channel_id INTEGER NOT NULL,
-	downloaded INTEGER NOT NULL,
+	downloaded INTEGER NOT NULL, thumbnail VARCHAR(256) NOT NULL DEFAULT '', path VARCHAR(256) NOT NULL DEFAULT '', watched INTEGER NOT NULL DEFAULT 0, found DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
 	PRIMARY KEY (id),
 	CONSTRAINT fk_channel
 		FOREIGN KEY(channel_id)
@@ -27,7 +27,70 @@ CREATE TABLE IF NOT EXISTS 'videos_fts_idx'(segid, term, pgno, PRIMARY KEY(segid
 CREATE TABLE IF NOT EXISTS 'videos_fts_content'(id INTEGER PRIMARY KEY, c0, c1, c2);
 CREATE TABLE IF NOT EXISTS 'videos_fts_docsize'(id INTEGER PRIMARY KEY, sz BLOB);
 CREATE TABLE IF NOT EXISTS 'videos_fts_config'(k PRIMARY KEY, v) WITHOUT ROWID;
+CREATE TABLE playlists (
+	name VARCHAR(64) NOT NULL,
+	autodownload INTEGER NOT NULL,
+	PRIMARY KEY (name)
+);
+CREATE TABLE playlist_videos (
+	playlist VARCHAR(64) NOT NULL,
+	video_id VARCHAR(64) NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (playlist, video_id),
+	CONSTRAINT fk_playlist
+		FOREIGN KEY(playlist)
+		REFERENCES playlists (name)
+		ON DELETE CASCADE,
+	CONSTRAINT fk_video
+		FOREIGN KEY(video_id)
+		REFERENCES videos (id)
+		ON DELETE CASCADE
+);
+CREATE TABLE download_queue (
+	video_id VARCHAR(64) NOT NULL,
+	queued DATETIME NOT NULL,
+	PRIMARY KEY (video_id),
+	CONSTRAINT fk_video
+		FOREIGN KEY(video_id)
+		REFERENCES videos (id)
+		ON DELETE CASCADE
+);
+CREATE TABLE locks (
+	name VARCHAR(64) NOT NULL,
+	token VARCHAR(64) NOT NULL,
+	holder VARCHAR(256) NOT NULL,
+	acquired DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	PRIMARY KEY (name)
+);
+CREATE TABLE state (
+	name VARCHAR(64) NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (name)
+);
+CREATE TABLE websub (
+	channel_id VARCHAR(64) NOT NULL,
+	hub VARCHAR(256) NOT NULL,
+	topic VARCHAR(256) NOT NULL,
+	secret VARCHAR(64) NOT NULL,
+	requested DATETIME NOT NULL,
+	expires DATETIME,
+	PRIMARY KEY (channel_id),
+	CONSTRAINT fk_channel
+		FOREIGN KEY(channel_id)
+		REFERENCES channels (id)
+		ON DELETE CASCADE
+);
 -- Dbmate schema migrations
 INSERT INTO "schema_migrations" (version) VALUES
   ('01'),
-  ('02');
+  ('02'),
+  ('03'),
+  ('04'),
+  ('05'),
+  ('06'),
+  ('07'),
+  ('08'),
+  ('09'),
+  ('10'),
+  ('11');
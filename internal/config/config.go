@@ -3,85 +3,646 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	defaultPath   string = ".yrs"
+	appName       string = "yrs"
+	legacyPath    string = ".yrs"
 	defaultName   string = "config.yml"
 	defaultDbName string = "yrs.db"
+
+	// EnvPrefix starts the name of the environment variables overriding the
+	// settings of the config file, like YRS_WEB_PORT for web.port
+	EnvPrefix string = "YRS_"
+	// EnvConfig points to the config file when not given explicitly
+	EnvConfig string = EnvPrefix + "CONFIG"
 )
 
+// Config is the schema of the config file. Every setting but the database
+// ones is optional, and can be overridden through its environment variable.
 type Config struct {
-	DatabaseDriver string `yaml:"database_driver"`
-	DatabaseUrl    string `yaml:"database_url"`
+	DatabaseDriver string `yaml:"database_driver,omitempty"`
+	DatabaseUrl    string `yaml:"database_url,omitempty"`
+	// UpdateInterval is the time between updates of the subscriptions, for
+	// the processes running them periodically
+	UpdateInterval time.Duration `yaml:"update_interval,omitempty"`
+	// DisableAfterFailures disables the channels whose feeds couldn't be
+	// fetched by that many updates in a row. They're never disabled when 0.
+	DisableAfterFailures int `yaml:"disable_after_failures,omitempty"`
+	// DownloadDir is where videos are downloaded to
+	DownloadDir string    `yaml:"download_dir,omitempty"`
+	HTTP        HTTP      `yaml:"http,omitempty"`
+	Retention   Retention `yaml:"retention,omitempty"`
+	Player      Player    `yaml:"player,omitempty"`
+	Web         Web       `yaml:"web,omitempty"`
+	WebSub      WebSub    `yaml:"websub,omitempty"`
+	Digest      Digest    `yaml:"digest,omitempty"`
+	// Notifiers are told about the new videos found by every update
+	Notifiers []Notifier `yaml:"notifiers,omitempty"`
+	// Tags group channels, by name or ID, to filter the notifications
+	Tags map[string][]string `yaml:"tags,omitempty"`
+	// Hooks run a command or call a URL on the events of yrs
+	Hooks []Hook `yaml:"hooks,omitempty"`
 }
 
-func Load(configPath string) (*Config, error) {
-	mayInitConfig := false
-	if configPath == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, err
-		}
-		configPath = filepath.Join(home, defaultPath, defaultName)
-		mayInitConfig = true
+// HTTP configures the requests made to fetch feeds and resolve channels
+type HTTP struct {
+	// Timeout is how long to wait for servers to connect and answer
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// UserAgent replaces the default User-Agent header of the requests
+	UserAgent string `yaml:"user_agent,omitempty"`
+	// Proxy is the URL of an HTTP or SOCKS5 proxy, like socks5://host:1080
+	Proxy string `yaml:"proxy,omitempty"`
+	// MinInterval is the minimum time between requests
+	MinInterval time.Duration `yaml:"min_interval,omitempty"`
+	// MaxRetries is how many times throttled requests are retried
+	MaxRetries int `yaml:"max_retries,omitempty"`
+}
+
+// Retention configures which videos are kept around when pruning
+type Retention struct {
+	// KeepLast is the number of videos to keep for each channel
+	KeepLast int `yaml:"keep_last,omitempty"`
+	// MaxAgeDays is the age after which videos are pruned
+	MaxAgeDays int `yaml:"max_age_days,omitempty"`
+	// AfterUpdate prunes the videos after each update
+	AfterUpdate bool `yaml:"after_update,omitempty"`
+}
+
+func (r Retention) MaxAge() time.Duration {
+	return time.Duration(r.MaxAgeDays) * 24 * time.Hour
+}
+
+// Player configures the external player used to watch videos
+type Player struct {
+	// Command is the player followed by its arguments, like [mpv, --fs]. The
+	// URL of the video, or the file it was downloaded to, is appended.
+	Command []string `yaml:"command,omitempty"`
+	// WatchedOnSuccess only marks videos as watched when the player exits
+	// successfully
+	WatchedOnSuccess bool `yaml:"watched_on_success,omitempty"`
+}
+
+// Web configures where the web interface is served
+type Web struct {
+	Address string `yaml:"address,omitempty"`
+	Port    int    `yaml:"port,omitempty"`
+	// RootUrl is the path the interface is served under, behind a proxy
+	RootUrl string `yaml:"root_url,omitempty"`
+}
+
+// WebSub configures the subscriptions to a hub pushing the new videos of the
+// YouTube channels to the web interface, as soon as they're published
+type WebSub struct {
+	// CallbackURL is the public URL of the web interface, like
+	// https://example.org/yrs. WebSub is only used when set.
+	CallbackURL string `yaml:"callback_url,omitempty"`
+	Hub         string `yaml:"hub,omitempty"`
+	// Lease is how long subscriptions last before being renewed, left to the
+	// hub when 0
+	Lease time.Duration `yaml:"lease,omitempty"`
+}
+
+// Digest configures the emails listing the unwatched videos found since the
+// previous one
+type Digest struct {
+	// Interval is the time between the digests sent by the daemon, like 24h
+	// or 168h. None are sent when 0.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Address is the one of the SMTP server, like smtp.example.org:587
+	Address  string   `yaml:"address,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	// HTMLTemplate and TextTemplate are Go templates replacing the default
+	// ones of the email
+	HTMLTemplate string `yaml:"html_template,omitempty"`
+	TextTemplate string `yaml:"text_template,omitempty"`
+}
+
+// The types of notifiers
+const (
+	NotifierSMTP    = "smtp"
+	NotifierWebhook = "webhook"
+	NotifierNtfy    = "ntfy"
+	NotifierGotify  = "gotify"
+	NotifierDesktop = "desktop"
+)
+
+// Notifier configures one of the destinations of the notifications
+type Notifier struct {
+	// Type is one of smtp, webhook, ntfy, gotify or desktop
+	Type string `yaml:"type"`
+	// URL is the one of the webhook, of the ntfy topic or of the Gotify
+	// server
+	URL string `yaml:"url,omitempty"`
+	// Token authenticates with ntfy or Gotify
+	Token string `yaml:"token,omitempty"`
+	// Address is the one of the SMTP server, like smtp.example.org:587
+	Address  string   `yaml:"address,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	// Command replaces notify-send for desktop notifications
+	Command []string `yaml:"command,omitempty"`
+	// Channels and Tags limit the notifications to the given channels, by
+	// name or ID, and to the ones with the given tags
+	Channels []string `yaml:"channels,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
+}
+
+// HookEvents are the events hooks can be run on. The progress events aren't
+// among them, as they're too frequent to run anything on.
+var HookEvents = []string{
+	"channel.added",
+	"channel.disabled",
+	"channel.removed",
+	"download.failed",
+	"download.finished",
+	"download.started",
+	"update.failed",
+	"update.finished",
+	"video.found",
+}
+
+// Hook configures a command run, or a URL posted to, on some events
+type Hook struct {
+	// Events limits the hook to the given events, like video.found. It runs
+	// on every event when empty.
+	Events []string `yaml:"events,omitempty"`
+	// Command is run with the event as JSON on its standard input and in
+	// YRS_* environment variables
+	Command []string `yaml:"command,omitempty"`
+	// URL is posted the event as JSON
+	URL string `yaml:"url,omitempty"`
+	// Secret signs the requests to URL in the X-Yrs-Signature header
+	Secret string `yaml:"secret,omitempty"`
+	// MaxRetries is how many times failed requests to URL are retried, 3
+	// when unset
+	MaxRetries int `yaml:"max_retries,omitempty"`
+	// Timeout limits how long the command runs or the request takes
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// NotifiedChannels returns the channels the notifier is limited to, by name
+// or ID, or nil if it's told about all of them
+func (c *Config) NotifiedChannels(n Notifier) []string {
+	if len(n.Channels) == 0 && len(n.Tags) == 0 {
+		return nil
+	}
+
+	channels := slices.Clone(n.Channels)
+	for _, tag := range n.Tags {
+		channels = append(channels, c.Tags[tag]...)
 	}
+	return channels
+}
 
-	tries := 0
+// Default returns the settings used for anything missing in the config file
+func Default() Config {
+	return Config{
+		DatabaseDriver: "sqlite3",
+		UpdateInterval: time.Hour,
+		DownloadDir:    ".",
+		HTTP: HTTP{
+			Timeout:    30 * time.Second,
+			MaxRetries: 3,
+		},
+		Web: Web{
+			Address: "127.0.0.1",
+			Port:    8080,
+		},
+		WebSub: WebSub{
+			Hub: "https://pubsubhubbub.appspot.com/subscribe",
+		},
+	}
+}
 
-OPEN:
-	f, err := os.Open(configPath)
+// Path returns the config file to use. Unless given explicitly or through
+// YRS_CONFIG, that's ~/.yrs/config.yml when it exists, and the one in the XDG
+// config directory, like ~/.config/yrs/config.yml, otherwise. isDefault
+// tells whether the file was chosen by yrs, and so it can be created.
+func Path(configPath string) (path string, isDefault bool, err error) {
+	if configPath != "" {
+		return configPath, false, nil
+	}
+	if p := os.Getenv(EnvConfig); p != "" {
+		return p, false, nil
+	}
+
+	home, err := os.UserHomeDir()
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) && tries == 0 && mayInitConfig {
-			if err := initialize(configPath); err != nil {
-				return nil, err
-			}
-			tries++
-			goto OPEN
-		} else {
+		return "", false, err
+	}
+	legacy := filepath.Join(home, legacyPath, defaultName)
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, true, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, appName, defaultName), true, nil
+}
+
+// Load reads the config file, creating it if it's the default one and it
+// doesn't exist yet, and applies the defaults and the environment overrides
+// on top. The result is validated.
+func Load(configPath string) (*Config, error) {
+	path, isDefault, err := Path(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	err = read(path, &config)
+	if errors.Is(err, fs.ErrNotExist) && isDefault {
+		if err := initialize(path); err != nil {
 			return nil, err
 		}
+		err = read(path, &config)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	var config Config
-	if err = yaml.NewDecoder(f).Decode(&config); err != nil {
+	if err := config.applyEnv(); err != nil {
 		return nil, err
 	}
+	config.DownloadDir = expandHome(config.DownloadDir)
+	config.Digest.HTMLTemplate = expandHome(config.Digest.HTMLTemplate)
+	config.Digest.TextTemplate = expandHome(config.Digest.TextTemplate)
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config in %s: %w", path, err)
+	}
 
 	return &config, nil
 }
 
-func initialize(configPath string) error {
-	fmt.Printf("Initializing config in %s\n", configPath)
-	home, err := os.UserHomeDir()
+// SetInFile sets the value of a setting in the config file, which is created
+// if it doesn't exist. The file must still be valid afterwards, although the
+// environment overrides are left aside.
+func SetInFile(path, key, value string) error {
+	var file Config
+	if err := read(path, &file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := file.Set(key, value); err != nil {
+		return err
+	}
+
+	merged := Default()
+	if err := read(path, &merged); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := merged.Set(key, value); err != nil {
+		return err
+	}
+	if err := merged.Validate(); err != nil {
+		return err
+	}
+
+	return file.Save(path)
+}
+
+func read(path string, config *Config) error {
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
-	dbDir := filepath.Join(home, defaultPath)
-	_, err = os.Stat(dbDir)
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	if err := dec.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Save writes the config to the given file
+func (c *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
-		if err := os.Mkdir(dbDir, 0750); err != nil {
-			return err
+		return err
+	}
+
+	if err := yaml.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Validate checks that the settings make sense, reporting all the problems
+// found at once
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	invalid := func(key string, value any, reason string) {
+		errs = append(errs, fmt.Errorf("invalid %s %v: %s", key, value, reason))
+	}
+
+	if c.DatabaseDriver != "sqlite3" {
+		invalid("database_driver", c.DatabaseDriver, "only sqlite3 is supported")
+	}
+	if c.DatabaseUrl == "" {
+		invalid("database_url", `""`, "the database is required")
+	}
+	if c.UpdateInterval < time.Minute {
+		invalid("update_interval", c.UpdateInterval, "updating more than once a minute gets throttled")
+	}
+	if c.DisableAfterFailures < 0 {
+		invalid("disable_after_failures", c.DisableAfterFailures, "can't be negative")
+	}
+	if c.HTTP.Timeout < 0 {
+		invalid("http.timeout", c.HTTP.Timeout, "can't be negative")
+	}
+	if c.HTTP.MinInterval < 0 {
+		invalid("http.min_interval", c.HTTP.MinInterval, "can't be negative")
+	}
+	if c.HTTP.MaxRetries < 0 {
+		invalid("http.max_retries", c.HTTP.MaxRetries, "can't be negative")
+	}
+	if c.HTTP.Proxy != "" {
+		u, err := url.Parse(c.HTTP.Proxy)
+		if err != nil || !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) {
+			invalid("http.proxy", c.HTTP.Proxy, "must be an http://, https:// or socks5:// URL")
 		}
 	}
-	fullDbPath := filepath.Join(dbDir, defaultDbName)
-	c := Config{
-		DatabaseDriver: "sqlite3",
-		DatabaseUrl:    fmt.Sprintf("file:%s", fullDbPath),
+	if c.Retention.KeepLast < 0 {
+		invalid("retention.keep_last", c.Retention.KeepLast, "can't be negative")
+	}
+	if c.Retention.MaxAgeDays < 0 {
+		invalid("retention.max_age_days", c.Retention.MaxAgeDays, "can't be negative")
+	}
+	if c.Retention.AfterUpdate && c.Retention.KeepLast == 0 && c.Retention.MaxAgeDays == 0 {
+		invalid("retention.after_update", true, "needs retention.keep_last or retention.max_age_days")
+	}
+	if len(c.Player.Command) > 0 && c.Player.Command[0] == "" {
+		invalid("player.command", c.Player.Command, "the player can't be empty")
+	}
+	if c.Web.Port < 1 || c.Web.Port > 65535 {
+		invalid("web.port", c.Web.Port, "must be between 1 and 65535")
+	}
+	if c.Web.RootUrl != "" && !strings.HasPrefix(c.Web.RootUrl, "/") {
+		invalid("web.root_url", c.Web.RootUrl, "must be a path starting with /")
+	}
+	if c.WebSub.CallbackURL != "" {
+		if u, err := url.Parse(c.WebSub.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			invalid("websub.callback_url", c.WebSub.CallbackURL, "must be an http:// or https:// URL")
+		}
+		if u, err := url.Parse(c.WebSub.Hub); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			invalid("websub.hub", c.WebSub.Hub, "must be an http:// or https:// URL")
+		}
+	}
+	if c.WebSub.Lease < 0 {
+		invalid("websub.lease", c.WebSub.Lease, "can't be negative")
+	}
+	if c.Digest.Interval < 0 {
+		invalid("digest.interval", c.Digest.Interval, "can't be negative")
+	}
+	if c.Digest.Interval > 0 && (c.Digest.Address == "" || c.Digest.From == "" || len(c.Digest.To) == 0) {
+		invalid("digest.interval", c.Digest.Interval, "digest.address, digest.from and digest.to are required")
+	}
+	for i, n := range c.Notifiers {
+		key := fmt.Sprintf("notifiers[%d]", i)
+		switch n.Type {
+		case NotifierSMTP:
+			if n.Address == "" || n.From == "" || len(n.To) == 0 {
+				invalid(key, n.Type, "address, from and to are required")
+			}
+		case NotifierWebhook, NotifierNtfy, NotifierGotify:
+			if u, err := url.Parse(n.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				invalid(key+".url", n.URL, "must be an http:// or https:// URL")
+			}
+			if n.Type == NotifierGotify && n.Token == "" {
+				invalid(key+".token", `""`, "gotify needs the token of an application")
+			}
+		case NotifierDesktop:
+			if len(n.Command) > 0 && n.Command[0] == "" {
+				invalid(key+".command", n.Command, "the command can't be empty")
+			}
+		default:
+			invalid(key+".type", n.Type, "must be smtp, webhook, ntfy, gotify or desktop")
+		}
+		for _, tag := range n.Tags {
+			if _, ok := c.Tags[tag]; !ok {
+				invalid(key+".tags", tag, "not defined in tags")
+			}
+		}
+	}
+	for i, h := range c.Hooks {
+		key := fmt.Sprintf("hooks[%d]", i)
+		switch {
+		case len(h.Command) > 0 && h.URL != "":
+			invalid(key, h.URL, "either a command or a url is needed, not both")
+		case len(h.Command) > 0:
+			if h.Command[0] == "" {
+				invalid(key+".command", h.Command, "the command can't be empty")
+			}
+		case h.URL != "":
+			if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				invalid(key+".url", h.URL, "must be an http:// or https:// URL")
+			}
+		default:
+			invalid(key, `""`, "a command or a url is required")
+		}
+		for _, e := range h.Events {
+			if !slices.Contains(HookEvents, e) {
+				invalid(key+".events", e, "must be one of "+strings.Join(HookEvents, ", "))
+			}
+		}
+		if h.MaxRetries < 0 {
+			invalid(key+".max_retries", h.MaxRetries, "can't be negative")
+		}
+		if h.Timeout < 0 {
+			invalid(key+".timeout", h.Timeout, "can't be negative")
+		}
 	}
-	f, err := os.OpenFile(configPath, os.O_CREATE|os.O_RDWR, 0644)
+
+	return errors.Join(errs...)
+}
+
+// Keys returns the name of every setting, like web.port for the port in the
+// web section
+func Keys() []string {
+	keys := make([]string, 0)
+	walk(reflect.ValueOf(&Config{}).Elem(), "", func(key string, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// EnvName returns the environment variable overriding the given setting
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Get returns the value of the setting, as accepted by Set
+func (c *Config) Get(key string) (string, error) {
+	v, err := c.lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), " "), nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Set parses the value into the setting. Durations look like 1h30m, and
+// lists are separated by spaces.
+func (c *Config) Set(key, value string) error {
+	v, err := c.lookup(key)
 	if err != nil {
 		return err
 	}
 
-	if err := yaml.NewEncoder(f).Encode(c); err != nil {
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+func (c *Config) lookup(key string) (reflect.Value, error) {
+	var found reflect.Value
+	walk(reflect.ValueOf(c).Elem(), "", func(k string, v reflect.Value) {
+		if k == key {
+			found = v
+		}
+	})
+	if !found.IsValid() {
+		return found, fmt.Errorf(
+			"unknown setting %s, expected one of: %s",
+			key,
+			strings.Join(Keys(), ", "),
+		)
+	}
+	return found, nil
+}
+
+func (c *Config) applyEnv() error {
+	errs := make([]error, 0)
+	walk(reflect.ValueOf(c).Elem(), "", func(key string, v reflect.Value) {
+		name := EnvName(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := setValue(v, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", name, value, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk calls f for every setting in v, a struct, with its key and its value.
+// Nested structs are sections, their keys joined with a dot. Lists of sections
+// and maps, like the notifiers, can only be set in the file, so they're left
+// out.
+func walk(v reflect.Value, prefix string, f func(key string, v reflect.Value)) {
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		key := prefix + name
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			walk(field, key+".", f)
+		case field.Kind() == reflect.Map,
+			field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.String:
+		default:
+			f(key, field)
+		}
+	}
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice:
+		v.Set(reflect.ValueOf(strings.Fields(s)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// initialize creates the config file, pointing to a database in the XDG data
+// directory, like ~/.local/share/yrs/yrs.db
+func initialize(configPath string) error {
+	fmt.Fprintf(os.Stderr, "Initializing config in %s\n", configPath)
+
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	dbDir := filepath.Join(dataDir, appName)
+	if err := os.MkdirAll(dbDir, 0750); err != nil {
 		return err
 	}
 
-	return err
+	c := Config{
+		DatabaseDriver: "sqlite3",
+		DatabaseUrl:    fmt.Sprintf("file:%s", filepath.Join(dbDir, defaultDbName)),
+	}
+	return c.Save(configPath)
 }
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, "database_url: file:yrs.db\nweb:\n  port: 9000\n")
+	t.Setenv("YRS_UPDATE_INTERVAL", "90m")
+	t.Setenv("YRS_PLAYER_COMMAND", "mpv --fs")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		key string
+		exp string
+	}{
+		{key: "database_driver", exp: "sqlite3"},
+		{key: "database_url", exp: "file:yrs.db"},
+		{key: "update_interval", exp: (90 * time.Minute).String()},
+		{key: "player.command", exp: "mpv --fs"},
+		{key: "web.address", exp: "127.0.0.1"},
+		{key: "web.port", exp: "9000"},
+	}
+
+	for _, test := range testCases {
+		got, err := c.Get(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.exp {
+			t.Errorf("Unexpected value for %s. Got %s, Expected %s", test.key, got, test.exp)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	testCases := []struct {
+		config string
+		env    map[string]string
+		exp    []string
+	}{
+		{config: "database_url: file:yrs.db\ntypo: 1\n", exp: []string{"field typo not found"}},
+		{config: "", exp: []string{"database_url"}},
+		{
+			config: "database_url: file:yrs.db\nweb:\n  port: 70000\n  root_url: yrs\n",
+			exp:    []string{"web.port 70000", "web.root_url yrs"},
+		},
+		{
+			config: "database_url: file:yrs.db\ndigest:\n  interval: 24h\n  to: [me@example.org]\n",
+			exp:    []string{"digest.address, digest.from and digest.to are required"},
+		},
+		{
+			config: "database_url: file:yrs.db\nnotifiers:\n  - type: pager\n  - type: webhook\n    url: ftp://host\n    tags: [music]\n",
+			exp:    []string{"notifiers[0].type pager", "notifiers[1].url ftp://host", "notifiers[1].tags music"},
+		},
+		{
+			config: "database_url: file:yrs.db\nhooks:\n  - url: http://host\n    events: [video.lost]\n  - command: [x]\n    url: http://host\n  - secret: s\n",
+			exp:    []string{"hooks[0].events video.lost", "hooks[1] http://host", "hooks[2]"},
+		},
+		{
+			config: "database_url: file:yrs.db\nwebsub:\n  callback_url: example.org/yrs\n  lease: -1h\n",
+			exp:    []string{"websub.callback_url example.org/yrs", "websub.lease -1h"},
+		},
+		{
+			config: "database_url: file:yrs.db\nretention:\n  after_update: true\n",
+			exp:    []string{"retention.after_update true"},
+		},
+		{
+			config: "database_url: file:yrs.db\n",
+			env:    map[string]string{"YRS_HTTP_TIMEOUT": "soon"},
+			exp:    []string{"YRS_HTTP_TIMEOUT"},
+		},
+	}
+
+	for _, test := range testCases {
+		for k, v := range test.env {
+			t.Setenv(k, v)
+		}
+
+		_, err := Load(writeConfig(t, test.config))
+		if err == nil {
+			t.Errorf("Expected an error loading %q", test.config)
+			continue
+		}
+		for _, exp := range test.exp {
+			if !strings.Contains(err.Error(), exp) {
+				t.Errorf("Expected %q in the error. Got %q", exp, err)
+			}
+		}
+
+		for k := range test.env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestNotifiedChannels(t *testing.T) {
+	path := writeConfig(t, `database_url: file:yrs.db
+tags:
+  music: [Band, UC123]
+notifiers:
+  - type: desktop
+  - type: ntfy
+    url: https://ntfy.sh/videos
+    channels: [Tony]
+    tags: [music]
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channels := c.NotifiedChannels(c.Notifiers[0]); channels != nil {
+		t.Errorf("Unexpected channels for an unfiltered notifier: %v", channels)
+	}
+	exp := []string{"Tony", "Band", "UC123"}
+	if channels := c.NotifiedChannels(c.Notifiers[1]); !slices.Equal(channels, exp) {
+		t.Errorf("Unexpected channels. Got %v, Expected %v", channels, exp)
+	}
+	if slices.Contains(Keys(), "notifiers") || slices.Contains(Keys(), "tags") {
+		t.Errorf("Notifiers and tags can't be set through the environment, but got keys %v", Keys())
+	}
+}
+
+func TestSetInFile(t *testing.T) {
+	path := writeConfig(t, "database_url: file:yrs.db\n")
+
+	if err := SetInFile(path, "retention.keep_last", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetInFile(path, "web.port", "0"); err == nil {
+		t.Error("Expected an error setting an invalid port")
+	}
+	if err := SetInFile(path, "nope", "1"); err == nil {
+		t.Error("Expected an error setting an unknown setting")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "database_url: file:yrs.db\nretention:\n  keep_last: 10\n"
+	if string(content) != exp {
+		t.Errorf("Unexpected config file. Got %q, Expected %q", content, exp)
+	}
+}
+
+func TestPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(EnvConfig, "")
+
+	path, isDefault, err := Path("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := filepath.Join(home, ".config", "yrs", "config.yml"); path != exp || !isDefault {
+		t.Errorf("Unexpected default path. Got %s, Expected %s", path, exp)
+	}
+
+	legacy := filepath.Join(home, ".yrs", "config.yml")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, _, _ := Path(""); path != legacy {
+		t.Errorf("Unexpected legacy path. Got %s, Expected %s", path, legacy)
+	}
+
+	t.Setenv(EnvConfig, "/etc/yrs.yml")
+	if path, isDefault, _ := Path(""); path != "/etc/yrs.yml" || isDefault {
+		t.Errorf("Unexpected path from %s. Got %s", EnvConfig, path)
+	}
+}
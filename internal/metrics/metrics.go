@@ -0,0 +1,178 @@
+// Package metrics exposes the metrics of yrs to Prometheus
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	updateBuckets  = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// The results of fetching a feed
+const (
+	FeedFetched     = "fetched"
+	FeedFailed      = "failed"
+	FeedNotModified = "not_modified"
+)
+
+// Metrics follows the events of yrs, and the requests of the web interface,
+// to expose them to Prometheus
+type Metrics struct {
+	handler http.Handler
+
+	mu            sync.Mutex
+	updateStarted time.Time
+
+	updateDuration prometheus.Histogram
+	feeds          *prometheus.CounterVec
+	newVideos      *prometheus.CounterVec
+	lastSuccess    *prometheus.GaugeVec
+	requests       *prometheus.HistogramVec
+}
+
+// New builds the metrics of y, following its events from now on
+func New(y *yrs.Yrs) *Metrics {
+	channelLabels := []string{"channel_id", "channel"}
+	m := &Metrics{
+		updateDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "yrs_update_duration_seconds",
+			Help:    "Time taken by the updates.",
+			Buckets: updateBuckets,
+		}),
+		feeds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "yrs_feed_fetches_total",
+			Help: "Feeds fetched by the updates, by result.",
+		}, []string{"result"}),
+		newVideos: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "yrs_new_videos_total",
+			Help: "New videos found, by channel.",
+		}, channelLabels),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "yrs_channel_last_success_timestamp_seconds",
+			Help: "Last time the feed of the channel was fetched successfully.",
+		}, channelLabels),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "yrs_http_request_duration_seconds",
+			Help:    "Time taken by the web interface to answer, by route.",
+			Buckets: requestBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+	// Every result is exposed from the start, even before any update
+	m.feeds.WithLabelValues(FeedFetched)
+	m.feeds.WithLabelValues(FeedFailed)
+	m.feeds.WithLabelValues(FeedNotModified)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		m.updateDuration,
+		m.feeds,
+		m.newVideos,
+		m.lastSuccess,
+		m.requests,
+		&databaseCollector{y: y},
+	)
+	m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	y.Events().Subscribe(
+		m,
+		yrs.EventUpdateProgress,
+		yrs.EventUpdateFinished,
+		yrs.EventUpdateFailed,
+		yrs.EventVideoFound,
+	)
+	return m
+}
+
+func (m *Metrics) Handle(e yrs.Event) error {
+	switch e.Type {
+	case yrs.EventUpdateProgress:
+		// Updates start with the progress of no channels fetched
+		if e.Channel == nil {
+			m.mu.Lock()
+			m.updateStarted = e.Time
+			m.mu.Unlock()
+			return nil
+		}
+		switch {
+		case e.Error != "":
+			m.feeds.WithLabelValues(FeedFailed).Inc()
+			return nil
+		case e.NotModified:
+			m.feeds.WithLabelValues(FeedNotModified).Inc()
+		default:
+			m.feeds.WithLabelValues(FeedFetched).Inc()
+		}
+		m.lastSuccess.WithLabelValues(e.Channel.ID, e.Channel.Name).Set(float64(e.Time.Unix()))
+	case yrs.EventUpdateFinished, yrs.EventUpdateFailed:
+		// Failed channels are published before the update finishes
+		if e.Channel != nil {
+			return nil
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !m.updateStarted.IsZero() {
+			m.updateDuration.Observe(e.Time.Sub(m.updateStarted).Seconds())
+			m.updateStarted = time.Time{}
+		}
+	case yrs.EventVideoFound:
+		if c := e.Video.Channel; c != nil {
+			m.newVideos.WithLabelValues(c.ID, c.Name).Inc()
+		}
+	}
+	return nil
+}
+
+// ObserveRequest records how long the web interface took to answer a request
+// to the given route
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+// ServeHTTP serves the metrics to Prometheus
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
+
+var (
+	downloadQueueDesc = prometheus.NewDesc(
+		"yrs_download_queue_videos", "Videos waiting to be downloaded.", nil, nil,
+	)
+	databaseSizeDesc = prometheus.NewDesc(
+		"yrs_database_size_bytes", "Size of the database.", nil, nil,
+	)
+)
+
+// databaseCollector reads the metrics kept in the database when scraped,
+// skipping them when that fails
+type databaseCollector struct {
+	y *yrs.Yrs
+}
+
+func (c *databaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- downloadQueueDesc
+	ch <- databaseSizeDesc
+}
+
+func (c *databaseCollector) Collect(ch chan<- prometheus.Metric) {
+	if queue, err := c.y.DownloadQueueLength(); err == nil {
+		ch <- prometheus.MustNewConstMetric(downloadQueueDesc, prometheus.GaugeValue, float64(queue))
+	} else {
+		slog.Error("couldn't get the download queue for the metrics", "err", err)
+	}
+	if size, err := c.y.DatabaseSize(); err == nil {
+		ch <- prometheus.MustNewConstMetric(databaseSizeDesc, prometheus.GaugeValue, float64(size))
+	} else {
+		slog.Error("couldn't get the database size for the metrics", "err", err)
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+)
+
+func TestMetrics(t *testing.T) {
+	y, err := yrs.New("sqlite3", "file:"+filepath.Join(t.TempDir(), "yrs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(y)
+
+	start := time.Now()
+	channel := &yrs.Channel{ID: "c1", Name: `Say "hi"`}
+	for _, e := range []yrs.Event{
+		{Type: yrs.EventUpdateProgress, Time: start, Progress: &yrs.Progress{Total: 3}},
+		{Type: yrs.EventUpdateProgress, Time: start, Channel: channel},
+		{Type: yrs.EventUpdateProgress, Time: start, Channel: &yrs.Channel{ID: "c2"}, NotModified: true},
+		{Type: yrs.EventUpdateProgress, Time: start, Channel: &yrs.Channel{ID: "c3"}, Error: "404"},
+		{Type: yrs.EventVideoFound, Time: start, Video: &yrs.Video{Channel: channel}},
+		{Type: yrs.EventUpdateFailed, Time: start.Add(time.Second), Channel: &yrs.Channel{ID: "c3"}, Error: "404"},
+		{Type: yrs.EventUpdateFinished, Time: start.Add(3 * time.Second)},
+	} {
+		y.Events().Publish(e)
+	}
+	m.ObserveRequest("GET", "/list-videos", 200, 20*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	got := rec.Body.String()
+
+	for _, exp := range []string{
+		`yrs_update_duration_seconds_bucket{le="2.5"} 0`,
+		`yrs_update_duration_seconds_bucket{le="5"} 1`,
+		`yrs_update_duration_seconds_sum 3`,
+		`yrs_feed_fetches_total{result="failed"} 1`,
+		`yrs_feed_fetches_total{result="fetched"} 1`,
+		`yrs_feed_fetches_total{result="not_modified"} 1`,
+		`yrs_new_videos_total{channel="Say \"hi\"",channel_id="c1"} 1`,
+		`yrs_channel_last_success_timestamp_seconds{channel="Say \"hi\"",channel_id="c1"} `,
+		`yrs_channel_last_success_timestamp_seconds{channel="",channel_id="c2"} `,
+		`yrs_download_queue_videos 0`,
+		`yrs_database_size_bytes `,
+		`yrs_http_request_duration_seconds_bucket{method="GET",route="/list-videos",status="200",le="0.025"} 1`,
+		`yrs_http_request_duration_seconds_count{method="GET",route="/list-videos",status="200"} 1`,
+	} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("Expected %s in:\n%s", exp, got)
+		}
+	}
+	if strings.Contains(got, `channel_id="c3"`) {
+		t.Errorf("Expected no success for the failed channel in:\n%s", got)
+	}
+}
@@ -0,0 +1,385 @@
+// Package tui implements the full-screen terminal interface of yrs
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+	"github.com/rivo/tview"
+)
+
+const help = "[yellow]Tab[-] switch pane  [yellow]/[-] search  [yellow]Enter/o[-] open  " +
+	"[yellow]w[-] toggle watched  [yellow]d[-] queue download  [yellow]u[-] unwatched only  " +
+	"[yellow]r[-] reload  [yellow]q[-] quit"
+
+// titleColumn takes the space left in the video list by the other columns
+const titleColumn = 3
+
+// Opener opens the video, like in a player, and may mark it as watched. It
+// runs while the terminal is suspended, so it can take it over until it
+// returns.
+type Opener func(v yrs.Video) error
+
+type tui struct {
+	y    *yrs.Yrs
+	open Opener
+
+	app      *tview.Application
+	layout   *tview.Flex
+	channels *tview.List
+	videos   *tview.Table
+	search   *tview.InputField
+	status   *tview.TextView
+
+	// State of the video list
+	channelList []yrs.Channel
+	channel     string
+	channelName string
+	query       string
+	unwatched   bool
+	shown       []yrs.Video
+	queued      map[string]bool
+}
+
+// Run shows the interface until the user quits
+func Run(y *yrs.Yrs, open Opener) error {
+	t := &tui{
+		y:        y,
+		open:     open,
+		app:      tview.NewApplication(),
+		channels: tview.NewList(),
+		videos:   tview.NewTable(),
+		search:   tview.NewInputField(),
+		status:   tview.NewTextView(),
+	}
+
+	t.channels.ShowSecondaryText(false).SetHighlightFullLine(true)
+	t.channels.SetBorder(true).SetTitle(" Channels ")
+	t.channels.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		t.channel, t.channelName = "", ""
+		if index > 0 && index <= len(t.channelList) {
+			t.channel = t.channelList[index-1].ID
+			t.channelName = t.channelList[index-1].Name
+		}
+		t.query = ""
+		t.reloadVideos()
+	})
+	t.channels.SetSelectedFunc(func(int, string, string, rune) {
+		t.app.SetFocus(t.videos)
+	})
+
+	t.videos.SetSelectable(true, false).SetFixed(1, 0)
+	t.videos.SetBorder(true)
+	t.videos.SetSelectedFunc(func(int, int) { t.openSelected() })
+
+	t.search.SetLabel("Search: ").SetFieldBackgroundColor(tcell.ColorDefault)
+	t.search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			t.query = t.search.GetText()
+			t.reloadVideos()
+		}
+		t.layout.RemoveItem(t.search)
+		t.app.SetFocus(t.videos)
+	})
+
+	t.status.SetDynamicColors(true)
+	t.setStatus("")
+
+	panes := tview.NewFlex().
+		AddItem(t.channels, 0, 1, true).
+		AddItem(t.videos, 0, 3, false)
+	t.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(panes, 0, 1, true).
+		AddItem(t.status, 1, 0, false)
+
+	t.app.SetInputCapture(t.handleKey)
+
+	if err := t.reloadChannels(); err != nil {
+		return err
+	}
+	t.reloadVideos()
+
+	return t.app.SetRoot(t.layout, true).EnableMouse(true).Run()
+}
+
+func (t *tui) handleKey(ev *tcell.EventKey) *tcell.EventKey {
+	// Let the search field get everything while typing
+	if t.app.GetFocus() == t.search {
+		return ev
+	}
+
+	switch ev.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		if t.app.GetFocus() == t.channels {
+			t.app.SetFocus(t.videos)
+		} else {
+			t.app.SetFocus(t.channels)
+		}
+		return nil
+	case tcell.KeyEscape:
+		if t.query != "" {
+			t.query = ""
+			t.reloadVideos()
+		}
+		return nil
+	}
+
+	switch ev.Rune() {
+	case 'q':
+		t.app.Stop()
+	case '/':
+		t.search.SetText(t.query)
+		t.layout.AddItem(t.search, 1, 0, false)
+		t.app.SetFocus(t.search)
+	case 'o':
+		t.openSelected()
+	case 'w':
+		t.toggleWatched()
+	case 'd':
+		t.queueSelected()
+	case 'u':
+		t.unwatched = !t.unwatched
+		t.reloadVideos()
+	case 'r':
+		if err := t.reloadChannels(); err != nil {
+			t.setError(err)
+			return nil
+		}
+		t.reloadVideos()
+	default:
+		return ev
+	}
+	return nil
+}
+
+func (t *tui) reloadChannels() error {
+	channels, err := t.y.GetChannels()
+	if err != nil {
+		return err
+	}
+
+	current := t.channels.GetCurrentItem()
+	t.channelList = channels
+	t.channels.Clear()
+	t.channels.AddItem("All channels", "", 0, nil)
+	for _, c := range channels {
+		t.channels.AddItem(tview.Escape(c.Name), "", 0, nil)
+	}
+	t.channels.SetCurrentItem(min(current, len(channels)))
+
+	return nil
+}
+
+// reloadVideos fills the video list according to the selected channel, the
+// search terms and the unwatched filter
+func (t *tui) reloadVideos() {
+	videos, err := t.findVideos()
+	if err != nil {
+		t.setError(err)
+		return
+	}
+
+	queue, err := t.y.GetDownloadQueue()
+	if err != nil {
+		t.setError(err)
+		return
+	}
+	t.queued = make(map[string]bool, len(queue))
+	for _, v := range queue {
+		t.queued[v.ID] = true
+	}
+
+	row, _ := t.videos.GetSelection()
+	t.shown = videos
+	t.videos.Clear()
+	for col, header := range []string{"", "Published", "Channel", "Title"} {
+		t.videos.SetCell(0, col, tview.NewTableCell(header).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+	for i := range videos {
+		t.setVideoRow(i)
+	}
+	t.videos.Select(max(1, min(row, len(videos))), 0)
+
+	title := "All videos"
+	if t.channelName != "" {
+		title = t.channelName
+	}
+	if t.query != "" {
+		title = fmt.Sprintf("Search: %s", t.query)
+	}
+	if t.unwatched {
+		title += ", unwatched"
+	}
+	t.videos.SetTitle(fmt.Sprintf(" %s (%d) ", tview.Escape(title), len(videos)))
+	t.setStatus("")
+}
+
+func (t *tui) findVideos() ([]yrs.Video, error) {
+	if t.query == "" {
+		return t.y.QueryVideos(yrs.VideoQuery{
+			Channel:   t.channel,
+			Unwatched: t.unwatched,
+			Sort:      yrs.SortNewest,
+		})
+	}
+
+	results, err := t.y.Search(t.query)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	found, err := t.y.GetVideosByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the ranking of the search
+	byID := make(map[string]yrs.Video, len(found))
+	for _, v := range found {
+		byID[v.ID] = v
+	}
+	videos := make([]yrs.Video, 0, len(found))
+	for _, id := range ids {
+		if v, ok := byID[id]; ok && (!t.unwatched || !v.Watched) {
+			videos = append(videos, v)
+		}
+	}
+	return videos, nil
+}
+
+// setVideoRow renders the i-th video shown, right after the header
+func (t *tui) setVideoRow(i int) {
+	v := t.shown[i]
+
+	flags := []rune("  ")
+	if !v.Watched {
+		flags[0] = '*'
+	}
+	if v.Downloaded {
+		flags[1] = 'D'
+	} else if t.queued[v.ID] {
+		flags[1] = 'Q'
+	}
+
+	channel := ""
+	if v.Channel != nil {
+		channel = v.Channel.Name
+	}
+
+	color := tcell.ColorDefault
+	if v.Watched {
+		color = tcell.ColorGray
+	}
+
+	for col, text := range []string{
+		string(flags),
+		v.Published.Local().Format(time.DateOnly),
+		channel,
+		v.Title,
+	} {
+		cell := tview.NewTableCell(tview.Escape(text)).SetTextColor(color)
+		if col == titleColumn {
+			cell.SetExpansion(1)
+		} else {
+			cell.SetMaxWidth(30)
+		}
+		t.videos.SetCell(i+1, col, cell)
+	}
+}
+
+func (t *tui) selected() (int, bool) {
+	row, _ := t.videos.GetSelection()
+	if row < 1 || row > len(t.shown) {
+		return 0, false
+	}
+	return row - 1, true
+}
+
+func (t *tui) openSelected() {
+	i, ok := t.selected()
+	if !ok {
+		return
+	}
+
+	var err error
+	t.app.Suspend(func() {
+		err = t.open(t.shown[i])
+	})
+	t.refreshVideo(i)
+	if err != nil {
+		t.setError(err)
+	}
+}
+
+// refreshVideo reloads the i-th video shown, like after opening it
+func (t *tui) refreshVideo(i int) {
+	videos, err := t.y.GetVideosByID([]string{t.shown[i].ID})
+	if err != nil {
+		t.setError(err)
+		return
+	}
+	if len(videos) == 1 {
+		t.shown[i] = videos[0]
+		t.setVideoRow(i)
+	}
+}
+
+func (t *tui) toggleWatched() {
+	if i, ok := t.selected(); ok {
+		t.setWatched(i, !t.shown[i].Watched)
+	}
+}
+
+func (t *tui) setWatched(i int, watched bool) {
+	v := &t.shown[i]
+	if err := t.y.SetWatched(watched, v.ID); err != nil {
+		t.setError(err)
+		return
+	}
+	v.Watched = watched
+	t.setVideoRow(i)
+}
+
+func (t *tui) queueSelected() {
+	i, ok := t.selected()
+	if !ok {
+		return
+	}
+
+	v := t.shown[i]
+	if v.Downloaded {
+		t.setStatus(fmt.Sprintf("%s was already downloaded", v.Title))
+		return
+	}
+	if err := t.y.QueueDownload(v.ID); err != nil {
+		t.setError(err)
+		return
+	}
+	t.queued[v.ID] = true
+	t.setVideoRow(i)
+	t.setStatus(fmt.Sprintf("Queued %s, run yrs download to fetch it", v.Title))
+}
+
+// setStatus shows msg in the status line, or the key bindings without it
+func (t *tui) setStatus(msg string) {
+	if msg == "" {
+		t.status.SetText(help)
+		return
+	}
+	t.status.SetText(tview.Escape(msg))
+}
+
+func (t *tui) setError(err error) {
+	t.status.SetText("[red]" + tview.Escape(err.Error()))
+}
@@ -0,0 +1,175 @@
+package yrs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// retryBaseDelay is the backoff before the first retry, doubling with every
+// one after it
+var retryBaseDelay = 2 * time.Second
+
+// maxRetryAfter caps how long servers can ask to wait before retrying
+const maxRetryAfter = 5 * time.Minute
+
+// HTTPOptions configures the client built by NewHTTPClient. Zero values keep
+// the defaults of net/http.
+type HTTPOptions struct {
+	// Timeout limits how long to wait for servers to connect and to answer.
+	// Reading the body isn't limited, so large downloads can take their time.
+	Timeout time.Duration
+	// UserAgent replaces the User-Agent header of the requests
+	UserAgent string
+	// Proxy is the URL of an HTTP, HTTPS or SOCKS5 proxy. Without it, the
+	// usual HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are honored.
+	Proxy string
+	// MinInterval spaces out the requests made through the client, across
+	// all of its users
+	MinInterval time.Duration
+	// MaxRetries is the number of times requests are retried when servers
+	// answer with 429 Too Many Requests or with a 5xx error, backing off
+	// exponentially in between
+	MaxRetries int
+}
+
+// NewHTTPClient builds the client used for every request made by Yrs
+func NewHTTPClient(o HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Timeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   o.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = o.Timeout
+		transport.ResponseHeaderTimeout = o.Timeout
+	}
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %w", o.Proxy, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %s", u.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	var rt http.RoundTripper = transport
+	if o.UserAgent != "" {
+		rt = &userAgentTransport{userAgent: o.UserAgent, next: rt}
+	}
+	if o.MinInterval > 0 || o.MaxRetries > 0 {
+		rt = &limitTransport{
+			next:        rt,
+			minInterval: o.MinInterval,
+			maxRetries:  o.MaxRetries,
+		}
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
+
+// limitTransport spaces out the requests and retries the throttled ones.
+// Backing off delays every request going through it, not only the retried
+// one, as servers throttle clients rather than requests.
+type limitTransport struct {
+	next        http.RoundTripper
+	minInterval time.Duration
+	maxRetries  int
+
+	mu sync.Mutex
+	// slot is the earliest time for the next request
+	slot time.Time
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retriable := (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		res, err := t.next.RoundTrip(req)
+		if err != nil || !retriable || attempt >= t.maxRetries || !throttled(res.StatusCode) {
+			return res, err
+		}
+
+		t.delay(retryDelay(res, attempt))
+		io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+		res.Body.Close()
+	}
+}
+
+// wait blocks until the turn of the caller comes
+func (t *limitTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.slot
+	if slot.Before(now) {
+		slot = now
+	}
+	t.slot = slot.Add(t.minInterval)
+	t.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay holds every request back for d
+func (t *limitTransport) delay(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.slot) {
+		t.slot = until
+	}
+}
+
+func throttled(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryDelay honors the Retry-After header, in seconds, and otherwise backs
+// off exponentially with some jitter, so that concurrent requests don't
+// retry all at once
+func retryDelay(res *http.Response, attempt int) time.Duration {
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s >= 0 {
+		return min(time.Duration(s)*time.Second, maxRetryAfter)
+	}
+
+	return backoff(attempt)
+}
+
+// backoff doubles retryBaseDelay with every attempt, with some jitter
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	return d/2 + rand.N(d/2+1)
+}
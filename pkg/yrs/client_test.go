@@ -0,0 +1,115 @@
+package yrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientRetries(t *testing.T) {
+	retryBaseDelay = 10 * time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = 2 * time.Second })
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != "yrs-test" {
+			t.Errorf("Unexpected user agent. Got %s, Expected %s", r.UserAgent(), "yrs-test")
+		}
+		switch requests.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		retries int
+		status  int
+		exp     int32
+	}{
+		{retries: 3, status: http.StatusOK, exp: 3},
+		{retries: 1, status: http.StatusServiceUnavailable, exp: 2},
+		{retries: 0, status: http.StatusTooManyRequests, exp: 1},
+	}
+
+	for _, test := range testCases {
+		requests.Store(0)
+		client, err := NewHTTPClient(HTTPOptions{UserAgent: "yrs-test", MaxRetries: test.retries})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != test.status || requests.Load() != test.exp {
+			t.Errorf(
+				"Unexpected result with %d retries. Got %d after %d requests, Expected %d after %d",
+				test.retries, res.StatusCode, requests.Load(), test.status, test.exp,
+			)
+		}
+	}
+}
+
+func TestHTTPClientMinInterval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	interval := 50 * time.Millisecond
+	client, err := NewHTTPClient(HTTPOptions{MinInterval: interval})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for range 3 {
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("Requests weren't spaced out. Took %s, Expected at least %s", elapsed, 2*interval)
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxied atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.Host == "example.invalid")
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.Get("http://example.invalid/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if !proxied.Load() {
+		t.Error("The request didn't go through the proxy")
+	}
+
+	for _, p := range []string{"ftp://host", "://"} {
+		if _, err := NewHTTPClient(HTTPOptions{Proxy: p}); err == nil {
+			t.Errorf("Expected an error for proxy %s", p)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+-- migrate:up
+ALTER TABLE channels ADD COLUMN provider VARCHAR(32) NOT NULL DEFAULT 'rss';
+UPDATE channels SET provider='youtube' WHERE rss LIKE 'https://www.youtube.com/feeds/videos.xml?%';
+ALTER TABLE videos ADD COLUMN thumbnail VARCHAR(256) NOT NULL DEFAULT '';
+
+-- migrate:down
+ALTER TABLE videos DROP COLUMN thumbnail;
+ALTER TABLE channels DROP COLUMN provider;
@@ -0,0 +1,6 @@
+-- migrate:up
+ALTER TABLE channels ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'channel';
+UPDATE channels SET kind='playlist' WHERE rss LIKE '%playlist_id=%';
+
+-- migrate:down
+ALTER TABLE channels DROP COLUMN kind;
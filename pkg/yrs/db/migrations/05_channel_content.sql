@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE channels ADD COLUMN include_shorts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE channels ADD COLUMN include_live INTEGER NOT NULL DEFAULT 1;
+
+-- migrate:down
+ALTER TABLE channels DROP COLUMN include_live;
+ALTER TABLE channels DROP COLUMN include_shorts;
@@ -0,0 +1,37 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS playlists (
+	name VARCHAR(64) NOT NULL,
+	autodownload INTEGER NOT NULL,
+	PRIMARY KEY (name)
+);
+CREATE TABLE IF NOT EXISTS playlist_videos (
+	playlist VARCHAR(64) NOT NULL,
+	video_id VARCHAR(64) NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (playlist, video_id),
+	CONSTRAINT fk_playlist
+		FOREIGN KEY(playlist)
+		REFERENCES playlists (name)
+		ON DELETE CASCADE,
+	CONSTRAINT fk_video
+		FOREIGN KEY(video_id)
+		REFERENCES videos (id)
+		ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS download_queue (
+	video_id VARCHAR(64) NOT NULL,
+	queued DATETIME NOT NULL,
+	PRIMARY KEY (video_id),
+	CONSTRAINT fk_video
+		FOREIGN KEY(video_id)
+		REFERENCES videos (id)
+		ON DELETE CASCADE
+);
+INSERT INTO playlists (name, autodownload) VALUES ('watch-later', 0);
+ALTER TABLE videos ADD COLUMN path VARCHAR(256) NOT NULL DEFAULT '';
+
+-- migrate:down
+ALTER TABLE videos DROP COLUMN path;
+DROP TABLE download_queue;
+DROP TABLE playlist_videos;
+DROP TABLE playlists;
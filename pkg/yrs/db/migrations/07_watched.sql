@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE videos ADD COLUMN watched INTEGER NOT NULL DEFAULT 0;
+
+-- migrate:down
+ALTER TABLE videos DROP COLUMN watched;
@@ -0,0 +1,12 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS locks (
+	name VARCHAR(64) NOT NULL,
+	token VARCHAR(64) NOT NULL,
+	holder VARCHAR(256) NOT NULL,
+	acquired DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	PRIMARY KEY (name)
+);
+
+-- migrate:down
+DROP TABLE locks;
@@ -0,0 +1,12 @@
+-- migrate:up
+ALTER TABLE videos ADD COLUMN found DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE videos SET found=published;
+CREATE TABLE IF NOT EXISTS state (
+	name VARCHAR(64) NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (name)
+);
+
+-- migrate:down
+DROP TABLE state;
+ALTER TABLE videos DROP COLUMN found;
@@ -0,0 +1,17 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS websub (
+	channel_id VARCHAR(64) NOT NULL,
+	hub VARCHAR(256) NOT NULL,
+	topic VARCHAR(256) NOT NULL,
+	secret VARCHAR(64) NOT NULL,
+	requested DATETIME NOT NULL,
+	expires DATETIME,
+	PRIMARY KEY (channel_id),
+	CONSTRAINT fk_channel
+		FOREIGN KEY(channel_id)
+		REFERENCES channels (id)
+		ON DELETE CASCADE
+);
+
+-- migrate:down
+DROP TABLE websub;
@@ -0,0 +1,11 @@
+-- migrate:up
+ALTER TABLE channels ADD COLUMN failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE channels ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN last_success DATETIME;
+ALTER TABLE channels ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0;
+
+-- migrate:down
+ALTER TABLE channels DROP COLUMN disabled;
+ALTER TABLE channels DROP COLUMN last_success;
+ALTER TABLE channels DROP COLUMN last_error;
+ALTER TABLE channels DROP COLUMN failures;
@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS pruned_videos (
+	id VARCHAR(64) NOT NULL,
+	channel_id VARCHAR(64) NOT NULL,
+	pruned DATETIME NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_channel
+		FOREIGN KEY(channel_id)
+		REFERENCES channels (id)
+		ON DELETE CASCADE
+);
+
+-- migrate:down
+DROP TABLE pruned_videos;
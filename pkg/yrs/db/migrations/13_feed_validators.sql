@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE channels ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN last_modified TEXT NOT NULL DEFAULT '';
+
+-- migrate:down
+ALTER TABLE channels DROP COLUMN last_modified;
+ALTER TABLE channels DROP COLUMN etag;
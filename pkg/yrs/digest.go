@@ -0,0 +1,190 @@
+package yrs
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	digestLock      = "digest"
+	lastDigestState = "last_digest"
+)
+
+// ErrDigestInProgress is returned by SendDigest when another digest is being
+// sent
+var ErrDigestInProgress = errors.New("digest already being sent")
+
+//go:embed templates/digest.html templates/digest.txt
+var digestFS embed.FS
+
+// Digest lists the unwatched videos found by the updates in a period of time,
+// grouped by channel
+type Digest struct {
+	// Since is exclusive and Until inclusive
+	Since    time.Time
+	Until    time.Time
+	Channels []DigestChannel
+	// Count is the number of videos across all the channels
+	Count int
+}
+
+// DigestChannel is a channel along with its videos in a digest
+type DigestChannel struct {
+	Channel Channel
+	Videos  []Video
+}
+
+// Subject is the one of the digest emails
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("%d new videos since %s", d.Count, d.Since.Local().Format("2006-01-02"))
+}
+
+// DigestTemplates render the digest emails, out of a Digest
+type DigestTemplates struct {
+	HTML *htmltemplate.Template
+	Text *texttemplate.Template
+}
+
+// ParseDigestTemplates reads the templates of the digest emails. Empty paths
+// use the default ones.
+func ParseDigestTemplates(htmlPath, textPath string) (DigestTemplates, error) {
+	var t DigestTemplates
+	var err error
+
+	if htmlPath == "" {
+		t.HTML, err = htmltemplate.ParseFS(digestFS, "templates/digest.html")
+	} else {
+		t.HTML, err = htmltemplate.New(filepath.Base(htmlPath)).ParseFiles(htmlPath)
+	}
+	if err != nil {
+		return t, fmt.Errorf("couldn't parse the HTML digest template: %w", err)
+	}
+
+	if textPath == "" {
+		t.Text, err = texttemplate.ParseFS(digestFS, "templates/digest.txt")
+	} else {
+		t.Text, err = texttemplate.New(filepath.Base(textPath)).ParseFiles(textPath)
+	}
+	if err != nil {
+		return t, fmt.Errorf("couldn't parse the text digest template: %w", err)
+	}
+
+	return t, nil
+}
+
+// Render returns the HTML and the text versions of the digest
+func (d *Digest) Render(t DigestTemplates) (string, string, error) {
+	var html, text bytes.Buffer
+	if err := t.HTML.Execute(&html, d); err != nil {
+		return "", "", fmt.Errorf("couldn't render the HTML digest: %w", err)
+	}
+	if err := t.Text.Execute(&text, d); err != nil {
+		return "", "", fmt.Errorf("couldn't render the text digest: %w", err)
+	}
+	return html.String(), text.String(), nil
+}
+
+// LastDigest returns when the last digest was sent, or the zero time if none
+// was sent yet
+func (y *Yrs) LastDigest() (time.Time, error) {
+	value, err := y.getState(lastDigestState)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// NewDigest lists the unwatched videos found since the last digest, or since
+// the given time if none was sent yet, and up to now
+func (y *Yrs) NewDigest(since time.Time) (*Digest, error) {
+	last, err := y.LastDigest()
+	if err != nil {
+		return nil, err
+	}
+	if !last.IsZero() {
+		since = last
+	}
+
+	// The period is stored in whole seconds, as that's what gets compared
+	d := &Digest{
+		Since:    since.UTC().Truncate(time.Second),
+		Until:    time.Now().UTC().Truncate(time.Second),
+		Channels: make([]DigestChannel, 0),
+	}
+	videos, err := y.QueryVideos(VideoQuery{
+		FoundAfter: d.Since,
+		FoundUntil: d.Until,
+		Unwatched:  true,
+		Sort:       SortChannel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range videos {
+		n := len(d.Channels)
+		if n == 0 || d.Channels[n-1].Channel.ID != v.ChannelId {
+			d.Channels = append(d.Channels, DigestChannel{Channel: *v.Channel})
+			n++
+		}
+		d.Channels[n-1].Videos = append(d.Channels[n-1].Videos, v)
+	}
+	d.Count = len(videos)
+
+	return d, nil
+}
+
+// SendDigest emails the digest of the videos found since the last one, or
+// since the given time if none was sent yet, and records it as sent. Nothing
+// is emailed when there are no new videos, but the digest is recorded anyway.
+func (y *Yrs) SendDigest(m *SMTPNotifier, t DigestTemplates, since time.Time) (*Digest, error) {
+	unlock, err := y.lock(digestLock, ErrDigestInProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	d, err := y.NewDigest(since)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Count > 0 {
+		html, text, err := d.Render(t)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.send(d.Subject(), text, html); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := y.setState(lastDigestState, d.Until.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("digest sent, but couldn't record it: %w", err)
+	}
+	return d, nil
+}
+
+func (y *Yrs) getState(name string) (string, error) {
+	var value string
+	err := y.db.QueryRow("SELECT value FROM state WHERE name=?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (y *Yrs) setState(name, value string) error {
+	_, err := y.db.Exec(`
+		INSERT INTO state (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value=excluded.value
+	`, name, value)
+	return err
+}
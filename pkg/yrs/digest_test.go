@@ -0,0 +1,113 @@
+package yrs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigest(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupQueryFixtures(t, y)
+
+	// Digests only cover whole seconds, so the fixtures are found a bit
+	// earlier than now
+	found := time.Now().UTC().Add(-10 * time.Minute)
+	if _, err := y.db.Exec("UPDATE videos SET found=?", found); err != nil {
+		t.Fatal(err)
+	}
+	videos, err := y.QueryVideos(VideoQuery{Channel: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := y.SetWatched(true, videos[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := y.NewDigest(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Count != 5 || len(d.Channels) != 2 || len(d.Channels[0].Videos) != 2 ||
+		d.Channels[1].Channel.Name != "second" {
+		t.Errorf("Unexpected digest with %d videos: %+v", d.Count, d.Channels)
+	}
+
+	if d, err := y.NewDigest(time.Now().Add(-5 * time.Minute)); err != nil || d.Count != 0 {
+		t.Errorf("Expected an empty digest for videos found earlier. Got %v, %v", d, err)
+	}
+
+	addr, received := serveSMTP(t)
+	m := &SMTPNotifier{Address: addr, From: "yrs@example.org", To: []string{"me@example.org"}}
+	templates, err := ParseDigestTemplates("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := y.SendDigest(m, templates, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	for _, exp := range []string{
+		"Subject: 5 new videos since",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/html; charset=UTF-8",
+		"second link 2",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("Expected %q in the digest. Got %q", exp, msg)
+		}
+	}
+
+	last, err := y.LastDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.Equal(sent.Until) {
+		t.Errorf("Unexpected last digest. Got %s, Expected %s", last, sent.Until)
+	}
+
+	// Nothing new since the last one, so nothing is sent
+	d, err = y.SendDigest(m, templates, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Count != 0 || !d.Since.Equal(sent.Until) {
+		t.Errorf("Unexpected digest after the last one, since %s with %d videos", d.Since, d.Count)
+	}
+}
+
+func TestDigestTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digest.txt")
+	tmpl := "{{ range .Channels }}{{ .Channel.Name }}: {{ len .Videos }}\n{{ end }}"
+	if err := os.WriteFile(path, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := ParseDigestTemplates("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Digest{Channels: []DigestChannel{
+		{Channel: Channel{Name: "first"}, Videos: make([]Video, 2)},
+		{Channel: Channel{Name: "<second>"}, Videos: make([]Video, 1)},
+	}}
+	html, text, err := d.Render(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "first: 2\n<second>: 1\n"; text != exp {
+		t.Errorf("Unexpected text digest. Got %q, Expected %q", text, exp)
+	}
+	if !strings.Contains(html, "&lt;second&gt;") {
+		t.Errorf("Expected the channel names to be escaped in the HTML digest. Got %q", html)
+	}
+
+	if _, err := ParseDigestTemplates(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("Expected an error with a missing template")
+	}
+}
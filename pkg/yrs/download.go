@@ -0,0 +1,347 @@
+package yrs
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDownloader is the external tool used for videos that can't be
+// downloaded directly
+const DefaultDownloader = "yt-dlp"
+
+func queueDownload(tx *sql.Tx, videoID string) error {
+	_, err := tx.Exec(`
+		INSERT INTO download_queue (video_id, queued)
+		SELECT id, ? FROM videos WHERE id=? AND downloaded=0
+		ON CONFLICT DO NOTHING
+	`, time.Now(), videoID)
+	if err != nil {
+		return fmt.Errorf("error queueing %s for download: %w", videoID, err)
+	}
+	return nil
+}
+
+// QueueDownload adds the videos to the download queue, unless they were
+// already downloaded
+func (y *Yrs) QueueDownload(videoIDs ...string) error {
+	videos, err := y.GetVideosByID(videoIDs)
+	if err != nil {
+		return err
+	}
+	if len(videos) != len(videoIDs) {
+		return fmt.Errorf("some of the videos were not found: %v", videoIDs)
+	}
+
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	for _, id := range videoIDs {
+		if err := queueDownload(tx, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetDownloadQueue returns the videos waiting to be downloaded, oldest first
+func (y *Yrs) GetDownloadQueue() ([]Video, error) {
+	rows, err := y.db.Query(fmt.Sprintf(`
+		SELECT %s, %s
+		FROM download_queue q
+		JOIN videos v ON (q.video_id=v.id)
+		JOIN channels c ON (v.channel_id=c.id)
+		ORDER BY q.queued
+	`, videoColumns, channelColumns))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't retrieve the download queue: %w", err)
+	}
+	defer rows.Close()
+
+	videos := make([]Video, 0)
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		videos = append(videos, v)
+	}
+
+	return videos, nil
+}
+
+// DownloadQueueLength returns the number of videos waiting to be downloaded
+func (y *Yrs) DownloadQueueLength() (int, error) {
+	var n int
+	if err := y.db.QueryRow("SELECT COUNT(*) FROM download_queue").Scan(&n); err != nil {
+		return 0, fmt.Errorf("couldn't count the download queue: %w", err)
+	}
+	return n, nil
+}
+
+// CheckDownloader runs the external downloader to get its version, failing
+// when it can't be run
+func (y *Yrs) CheckDownloader() (string, error) {
+	out, err := exec.Command(y.downloader, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("couldn't run %s: %w", y.downloader, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ProcessDownloadQueue downloads everything in the queue into dir. Failed
+// downloads stay in the queue to be retried later.
+func (y *Yrs) ProcessDownloadQueue(dir string) ([]Video, error) {
+	queue, err := y.GetDownloadQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	downloaded := make([]Video, 0)
+	errs := make([]error, 0)
+	for _, v := range queue {
+		p, err := y.Download(v, dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		v.Downloaded = true
+		v.Path = p
+		downloaded = append(downloaded, v)
+	}
+
+	return downloaded, errors.Join(errs...)
+}
+
+// Download fetches the video into dir, following the hints of the provider of
+// its channel, and records where it was saved
+func (y *Yrs) Download(v Video, dir string) (string, error) {
+	y.publish(Event{Type: EventDownloadStarted, Video: &v})
+	p, err := y.download(v, dir)
+	if err != nil {
+		y.publish(Event{Type: EventDownloadFailed, Video: &v, Error: err.Error()})
+		return "", err
+	}
+
+	v.Downloaded = true
+	v.Path = p
+	y.publish(Event{Type: EventDownloadFinished, Video: &v})
+	return p, nil
+}
+
+func (y *Yrs) download(v Video, dir string) (string, error) {
+	provider := GetProvider("")
+	if v.Channel != nil {
+		provider = GetProvider(v.Channel.Provider)
+	}
+	hints := provider.DownloadHints(v)
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+
+	var p string
+	var err error
+	if hints.Direct {
+		p, err = y.downloadDirect(v, hints, dir)
+	} else {
+		p, err = y.runDownloader(v, hints, dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s (%s): %w", v.ID, v.Title, err)
+	}
+
+	tx, err := y.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("error on begin: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE videos SET downloaded=1, path=? WHERE id=?", p, v.ID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	_, err = tx.Exec("DELETE FROM download_queue WHERE video_id=?", v.ID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	return p, tx.Commit()
+}
+
+func (y *Yrs) runDownloader(v Video, hints DownloadHints, dir string) (string, error) {
+	args := []string{
+		"--paths", dir,
+		"--output", "%(title)s-%(id)s.%(ext)s",
+		"--print", "after_move:filepath",
+		// Printing implies --quiet, which hides the progress otherwise
+		"--progress",
+		"--newline",
+		"--progress-template", "download:" + progressPrefix +
+			"%(progress.downloaded_bytes)s %(progress.total_bytes,progress.total_bytes_estimate)s",
+	}
+	args = append(args, hints.Args...)
+	args = append(args, hints.URL)
+
+	var stdout, stderr bytes.Buffer
+	report := y.progressReporter(v)
+	outProgress := &progressWriter{w: &stdout, report: report}
+	errProgress := &progressWriter{w: &stderr, report: report}
+	cmd := exec.Command(y.downloader, args...)
+	cmd.Stdout = outProgress
+	cmd.Stderr = errProgress
+
+	err := cmd.Run()
+	outProgress.flush()
+	errProgress.flush()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", y.downloader, err, strings.TrimSpace(stderr.String()))
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	return lines[len(lines)-1], nil
+}
+
+func (y *Yrs) downloadDirect(v Video, hints DownloadHints, dir string) (string, error) {
+	u, err := url.Parse(hints.URL)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := y.client.Get(hints.URL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error retrieving %s: %s", hints.URL, res.Status)
+	}
+
+	name := fmt.Sprintf("%s-%s%s", sanitizeFilename(v.Title), v.ID, path.Ext(u.Path))
+	p := filepath.Join(dir, name)
+
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+
+	body := &progressReader{r: res.Body, total: res.ContentLength, report: y.progressReporter(v)}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(p)
+		return "", err
+	}
+
+	return p, f.Close()
+}
+
+func sanitizeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// progressPrefix starts the lines with the progress of the downloader
+const progressPrefix = "[yrs-progress] "
+
+// progressReporter returns the function publishing the progress of the
+// download of v, at most every progressInterval but for the end of it. The
+// downloader reports from both its output and its errors, so the function can
+// be called from several goroutines, skipping the progress already published.
+func (y *Yrs) progressReporter(v Video) func(done, total int64) {
+	var (
+		mu       sync.Mutex
+		last     time.Time
+		reported Progress
+	)
+	return func(done, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		p := Progress{Done: done, Total: max(total, 0)}
+		if p == reported || time.Since(last) < progressInterval && (total <= 0 || done < total) {
+			return
+		}
+		last, reported = time.Now(), p
+		y.publish(Event{
+			Type:     EventDownloadProgress,
+			Video:    &v,
+			Progress: &p,
+		})
+	}
+}
+
+// progressReader reports how much was read from r
+type progressReader struct {
+	r      io.Reader
+	done   int64
+	total  int64
+	report func(done, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	p.report(p.done, p.total)
+	return n, err
+}
+
+// progressWriter passes on what's written to w, but for the lines with the
+// progress of the downloader, which are reported instead
+type progressWriter struct {
+	w      io.Writer
+	report func(done, total int64)
+	line   []byte
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+
+		line := p.line[:i+1]
+		if rest, ok := bytes.CutPrefix(line, []byte(progressPrefix)); ok {
+			// Sizes are missing, as NA, or estimated, as floats, at times
+			done, total, _ := strings.Cut(strings.TrimSpace(string(rest)), " ")
+			d, err := strconv.ParseFloat(done, 64)
+			if err == nil {
+				t, _ := strconv.ParseFloat(total, 64)
+				p.report(int64(d), int64(t))
+			}
+		} else if _, err := p.w.Write(line); err != nil {
+			return 0, err
+		}
+		p.line = p.line[i+1:]
+	}
+}
+
+// flush passes on the last line, when it didn't end with a new line
+func (p *progressWriter) flush() {
+	p.w.Write(p.line)
+	p.line = nil
+}
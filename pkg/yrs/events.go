@@ -0,0 +1,351 @@
+package yrs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType names the things happening in yrs that hooks can react to
+type EventType string
+
+const (
+	EventVideoFound       EventType = "video.found"
+	EventDownloadStarted  EventType = "download.started"
+	EventDownloadFinished EventType = "download.finished"
+	EventDownloadFailed   EventType = "download.failed"
+	EventChannelAdded     EventType = "channel.added"
+	EventChannelRemoved   EventType = "channel.removed"
+	EventChannelDisabled  EventType = "channel.disabled"
+	EventUpdateFailed     EventType = "update.failed"
+	EventUpdateFinished   EventType = "update.finished"
+
+	// Progress events are published often while updating and downloading,
+	// to show how far along they are
+	EventUpdateProgress   EventType = "update.progress"
+	EventDownloadProgress EventType = "download.progress"
+)
+
+// progressInterval is the minimum time between the progress events of a
+// download
+const progressInterval = 500 * time.Millisecond
+
+// Event is something that happened, along with the video or the channel it
+// happened to
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Video   *Video    `json:"video,omitempty"`
+	Channel *Channel  `json:"channel,omitempty"`
+	// Error tells what went wrong, for the failures
+	Error string `json:"error,omitempty"`
+	// Progress counts the channels fetched by an update, or the bytes
+	// fetched by a download
+	Progress *Progress `json:"progress,omitempty"`
+	// Count is the number of new videos found by a finished update
+	Count int `json:"count,omitempty"`
+	// NotModified tells that the feed of the channel didn't change since the
+	// previous update
+	NotModified bool `json:"not_modified,omitempty"`
+}
+
+// Progress tells how far along a task is. Total is 0 when unknown.
+type Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Hook reacts to the events it's subscribed to
+type Hook interface {
+	Handle(e Event) error
+}
+
+type subscription struct {
+	hook  Hook
+	types []EventType
+}
+
+// EventBus delivers the events published by Yrs to the hooks subscribed to
+// them. Hooks are run one after the other, as events happen, so they'd
+// better be quick, or be wrapped in a QueuedHook.
+type EventBus struct {
+	// OnError is called with the errors of the hooks, which are otherwise
+	// ignored
+	OnError func(e Event, err error)
+
+	mu   sync.RWMutex
+	subs []subscription
+}
+
+// WithEventBus makes Yrs publish its events to the given bus. A nil one is
+// ignored, keeping the empty bus of New.
+func WithEventBus(b *EventBus) Option {
+	return func(y *Yrs) {
+		if b != nil {
+			y.events = b
+		}
+	}
+}
+
+// Subscribe makes h handle the events of the given types, or every event if
+// none is given
+func (b *EventBus) Subscribe(h Hook, types ...EventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, subscription{hook: h, types: types})
+}
+
+// Publish hands e to every hook subscribed to its type
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if len(s.types) > 0 && !slices.Contains(s.types, e.Type) {
+			continue
+		}
+		if err := s.hook.Handle(e); err != nil && b.OnError != nil {
+			b.OnError(e, err)
+		}
+	}
+}
+
+// Close waits for the hooks handling the events on their own, like
+// QueuedHook, to finish with the ones handed to them
+func (b *EventBus) Close() error {
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+
+	errs := make([]error, 0)
+	for _, s := range subs {
+		if c, ok := s.hook.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueuedHook hands the events to another hook running on its own goroutine,
+// so that slow hooks, like an HTTPHook retrying its deliveries, don't hold up
+// whoever publishes them. Events are dropped while the queue is full.
+type QueuedHook struct {
+	hook    Hook
+	onError func(e Event, err error)
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+}
+
+// NewQueuedHook starts handling the events with h, queueing up to size of
+// them. The errors of h are passed to onError, when given.
+func NewQueuedHook(h Hook, size int, onError func(e Event, err error)) *QueuedHook {
+	q := &QueuedHook{
+		hook:    h,
+		onError: onError,
+		queue:   make(chan Event, size),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		for e := range q.queue {
+			if err := q.hook.Handle(e); err != nil && q.onError != nil {
+				q.onError(e, err)
+			}
+		}
+	}()
+	return q
+}
+
+func (q *QueuedHook) Handle(e Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return fmt.Errorf("hook closed, dropping %s", e.Type)
+	}
+
+	select {
+	case q.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("hook queue full, dropping %s", e.Type)
+	}
+}
+
+// Close stops taking events, waiting for the queued ones to be handled
+func (q *QueuedHook) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	return nil
+}
+
+// Events returns the bus Yrs publishes its events to, the one given with
+// WithEventBus or an empty one otherwise
+func (y *Yrs) Events() *EventBus {
+	return y.events
+}
+
+func (y *Yrs) publish(e Event) {
+	y.events.Publish(e)
+}
+
+// ExecHook runs a command for every event, with the event as JSON on its
+// standard input and its main fields in environment variables, like
+// YRS_EVENT, YRS_VIDEO_ID or YRS_CHANNEL_NAME
+type ExecHook struct {
+	Command []string
+	// Timeout kills the command when it runs for longer. No limit when 0.
+	Timeout time.Duration
+}
+
+func (h *ExecHook) Handle(e Event) error {
+	if len(h.Command) == 0 {
+		return errors.New("hook without a command")
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), eventEnv(e)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("hook %s failed on %s: %w: %s", h.Command[0], e.Type, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func eventEnv(e Event) []string {
+	env := []string{
+		"YRS_EVENT=" + string(e.Type),
+		"YRS_EVENT_TIME=" + e.Time.Format(time.RFC3339),
+	}
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	add("YRS_ERROR", e.Error)
+	if v := e.Video; v != nil {
+		add("YRS_VIDEO_ID", v.ID)
+		add("YRS_VIDEO_TITLE", v.Title)
+		add("YRS_VIDEO_URL", v.URL)
+		add("YRS_VIDEO_PATH", v.Path)
+	}
+	c := e.Channel
+	if c == nil && e.Video != nil {
+		c = e.Video.Channel
+	}
+	if c != nil {
+		add("YRS_CHANNEL_ID", c.ID)
+		add("YRS_CHANNEL_NAME", c.Name)
+		add("YRS_CHANNEL_URL", c.URL)
+	}
+	return env
+}
+
+// HTTPHook posts every event as JSON to a URL. With a secret, the body is
+// signed with HMAC-SHA256 in the X-Yrs-Signature header, like
+// sha256=<hex digest>. Deliveries failing with a network error or a 5xx or
+// 429 answer are retried, backing off exponentially.
+type HTTPHook struct {
+	URL        string
+	Secret     string
+	MaxRetries int
+	Client     *http.Client
+}
+
+// Sign returns the signature of body with secret, as sent in the
+// X-Yrs-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *HTTPHook) Handle(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// The same ID across retries lets receivers skip repeated deliveries
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	delivery := hex.EncodeToString(b)
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Yrs-Event", string(e.Type))
+		req.Header.Set("X-Yrs-Delivery", delivery)
+		if h.Secret != "" {
+			req.Header.Set("X-Yrs-Signature", Sign(h.Secret, body))
+		}
+
+		res, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+			res.Body.Close()
+			if res.StatusCode >= 200 && res.StatusCode <= 299 {
+				return nil
+			}
+			err = fmt.Errorf("%s", res.Status)
+			if !throttled(res.StatusCode) {
+				attempt = h.MaxRetries
+			}
+		}
+		if attempt >= h.MaxRetries {
+			return fmt.Errorf("hook %s failed on %s: %w", strings.SplitN(h.URL, "?", 2)[0], e.Type, err)
+		}
+
+		time.Sleep(backoff(attempt))
+	}
+}
@@ -0,0 +1,366 @@
+package yrs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHook struct {
+	events []Event
+}
+
+func (h *recordingHook) Handle(e Event) error {
+	h.events = append(h.events, e)
+	return nil
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestEventBus(t *testing.T) {
+	srv := newFeedServer(t)
+	srv.addItem("1", "one", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	all := &recordingHook{}
+	found := &recordingHook{}
+	bus := &EventBus{}
+	bus.Subscribe(all)
+	bus.Subscribe(found, EventVideoFound)
+
+	y := mustCreateYrs(t)
+	WithEventBus(bus)(y)
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.addItem("2", "two", time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC))
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.setFailing(true)
+	if _, err := y.Update(); !errors.Is(err, ErrChannelsFailed) {
+		t.Fatalf("Expected the channel to fail. Got %v", err)
+	}
+
+	channels, err := y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := y.Unsubscribe(channels[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []EventType{
+		EventChannelAdded,
+		EventUpdateProgress, EventUpdateProgress, EventVideoFound, EventUpdateFinished,
+		EventUpdateProgress, EventUpdateProgress, EventUpdateFailed, EventUpdateFinished,
+		EventChannelRemoved,
+	}
+	if got := eventTypes(all.events); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("Unexpected events. Got %v, Expected %v", got, exp)
+	}
+	if p := all.events[2].Progress; p == nil || p.Done != 1 || p.Total != 1 || all.events[2].Channel == nil {
+		t.Errorf("Unexpected progress %+v", all.events[2])
+	}
+	if v := all.events[3].Video; v == nil || v.Title != "two" || v.Channel == nil {
+		t.Errorf("Unexpected video in %+v", all.events[3])
+	}
+	if e := all.events[4]; e.Count != 1 {
+		t.Errorf("Unexpected end of the update %+v", e)
+	}
+	if e := all.events[6]; e.Channel == nil || !strings.Contains(e.Error, "404") {
+		t.Errorf("Expected the failure in the progress. Got %+v", e)
+	}
+	if e := all.events[7]; e.Channel == nil || e.Channel.RSS != srv.URL || !strings.Contains(e.Error, "404") {
+		t.Errorf("Unexpected failure %+v", e)
+	}
+	if e := all.events[8]; e.Count != 0 {
+		t.Errorf("Unexpected end of the failed update %+v", e)
+	}
+	if e := all.events[9]; e.Channel == nil || e.Channel.Name != "feed" {
+		t.Errorf("Unexpected removal %+v", e)
+	}
+	if len(found.events) != 1 || found.events[0].Type != EventVideoFound {
+		t.Errorf("Expected only the new video. Got %v", eventTypes(found.events))
+	}
+}
+
+func TestNilEventBus(t *testing.T) {
+	y := mustCreateYrs(t)
+	WithEventBus(nil)(y)
+	if y.Events() == nil {
+		t.Fatal("Expected the empty bus to be kept")
+	}
+	if err := setupFixtures(y); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type failingHook struct{}
+
+func (failingHook) Handle(e Event) error {
+	return errors.New("hook failed")
+}
+
+func TestEventBusErrors(t *testing.T) {
+	var failed []error
+	h := &recordingHook{}
+	bus := &EventBus{OnError: func(e Event, err error) { failed = append(failed, err) }}
+	bus.Subscribe(failingHook{})
+	bus.Subscribe(h)
+
+	bus.Publish(Event{Type: EventDownloadStarted})
+	if len(failed) != 1 {
+		t.Errorf("Expected the failure to be reported. Got %v", failed)
+	}
+	if len(h.events) != 1 || h.events[0].Time.IsZero() {
+		t.Errorf("Expected the event to go on to the next hook, with a time. Got %+v", h.events)
+	}
+}
+
+// blockingHook tells when it gets an event, and records it once released
+type blockingHook struct {
+	recordingHook
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHook) Handle(e Event) error {
+	h.started <- struct{}{}
+	<-h.release
+	return h.recordingHook.Handle(e)
+}
+
+func TestQueuedHook(t *testing.T) {
+	h := &blockingHook{started: make(chan struct{}, 3), release: make(chan struct{})}
+	var failed []error
+	bus := &EventBus{OnError: func(e Event, err error) { failed = append(failed, err) }}
+	bus.Subscribe(NewQueuedHook(h, 1, nil))
+
+	// The first event is being handled, the second one waits in the queue and
+	// the third one doesn't fit
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bus.Publish(Event{Type: EventDownloadStarted})
+		<-h.started
+		bus.Publish(Event{Type: EventDownloadFinished})
+		bus.Publish(Event{Type: EventDownloadFailed})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected publishing not to wait for the hook")
+	}
+	if len(failed) != 1 || !strings.Contains(failed[0].Error(), "dropping download.failed") {
+		t.Errorf("Expected the last event to be dropped. Got %v", failed)
+	}
+
+	close(h.release)
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	exp := []EventType{EventDownloadStarted, EventDownloadFinished}
+	if got := eventTypes(h.events); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("Expected the queued events to be handled before closing. Got %v", got)
+	}
+	bus.Publish(Event{Type: EventDownloadStarted})
+	if len(failed) != 2 {
+		t.Errorf("Expected the events published after closing to be dropped. Got %v", failed)
+	}
+}
+
+func TestHTTPHook(t *testing.T) {
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = 2 * time.Second })
+
+	var (
+		mu         sync.Mutex
+		attempts   int
+		deliveries = make(map[string]bool)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		deliveries[r.Header.Get("X-Yrs-Delivery")] = true
+
+		body, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get("X-Yrs-Signature"); sig != Sign("secret", body) {
+			t.Errorf("Unexpected signature %q", sig)
+		}
+		if ev := r.Header.Get("X-Yrs-Event"); ev != string(EventVideoFound) {
+			t.Errorf("Unexpected event header %q", ev)
+		}
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil || e.Video == nil || e.Video.ID != "1" {
+			t.Errorf("Unexpected event %s: %v", body, err)
+		}
+
+		if attempts < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	h := &HTTPHook{URL: srv.URL, Secret: "secret", MaxRetries: 2}
+	e := Event{Type: EventVideoFound, Time: time.Now(), Video: &notifyFixtures()[0]}
+	if err := h.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || len(deliveries) != 1 {
+		t.Errorf("Expected 3 attempts of the same delivery. Got %d of %d", attempts, len(deliveries))
+	}
+
+	attempts = 0
+	h.MaxRetries = 1
+	if err := h.Handle(e); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the hook to give up after a retry. Got %v", err)
+	}
+
+	// Rejected events aren't retried
+	rejected := 0
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejected++
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	h = &HTTPHook{URL: bad.URL, MaxRetries: 3}
+	if err := h.Handle(e); err == nil || rejected != 1 {
+		t.Errorf("Expected a single rejected attempt. Got %d, %v", rejected, err)
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	h := &ExecHook{
+		Command: []string{"sh", "-c", `{ echo "$YRS_EVENT $YRS_VIDEO_ID $YRS_CHANNEL_NAME"; cat; } > "$0"`, out},
+		Timeout: 10 * time.Second,
+	}
+	e := Event{Type: EventDownloadFinished, Time: time.Now(), Video: &notifyFixtures()[0]}
+	if err := h.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, payload, _ := strings.Cut(string(b), "\n")
+	if env != "download.finished 1 First" {
+		t.Errorf("Unexpected environment %q", env)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(payload), &got); err != nil || got.Video == nil || got.Video.Title != "one" {
+		t.Errorf("Unexpected payload %q: %v", payload, err)
+	}
+
+	h = &ExecHook{Command: []string{"sh", "-c", "echo broken >&2; exit 1"}}
+	if err := h.Handle(e); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the output of the failed hook. Got %v", err)
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	dir := t.TempDir()
+	// Stands in for yt-dlp, printing its progress along with the file
+	downloader := filepath.Join(dir, "downloader")
+	script := `#!/bin/sh
+echo "[yrs-progress] NA NA"
+echo "[yrs-progress] 512 1024.0"
+echo "[yrs-progress] 1024 1024"
+echo "$2/video.mp4"
+`
+	if err := os.WriteFile(downloader, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	y := mustCreateYrs(t)
+	if err := setupFixtures(y); err != nil {
+		t.Fatal(err)
+	}
+	y.downloader = downloader
+	h := &recordingHook{}
+	y.Events().Subscribe(h, EventDownloadStarted, EventDownloadProgress, EventDownloadFinished)
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := y.Download(videos[0], dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != filepath.Join(dir, "video.mp4") {
+		t.Errorf("Unexpected path %s", p)
+	}
+
+	// Progress without sizes is skipped
+	exp := []EventType{EventDownloadStarted, EventDownloadProgress, EventDownloadProgress, EventDownloadFinished}
+	if got := eventTypes(h.events); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("Unexpected events. Got %v, Expected %v", got, exp)
+	}
+	if p := h.events[2].Progress; p.Done != 1024 || p.Total != 1024 {
+		t.Errorf("Unexpected progress %+v", p)
+	}
+	if v := h.events[3].Video; !v.Downloaded || v.Path != filepath.Join(dir, "video.mp4") {
+		t.Errorf("Unexpected downloaded video %+v", v)
+	}
+}
+
+func TestDownloadProgressFromBothStreams(t *testing.T) {
+	dir := t.TempDir()
+	// Reports the progress from its output and its errors at the same time
+	downloader := filepath.Join(dir, "downloader")
+	script := `#!/bin/sh
+for i in $(seq 1 200); do echo "[yrs-progress] $i 200"; done &
+for i in $(seq 1 200); do echo "[yrs-progress] $i 200" >&2; done
+wait
+echo "$2/video.mp4"
+`
+	if err := os.WriteFile(downloader, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	y := mustCreateYrs(t)
+	if err := setupFixtures(y); err != nil {
+		t.Fatal(err)
+	}
+	y.downloader = downloader
+	h := &recordingHook{}
+	y.Events().Subscribe(h, EventDownloadProgress)
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.Download(videos[0], dir); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := 0
+	for _, e := range h.events {
+		if e.Progress.Done == e.Progress.Total {
+			finished++
+		}
+	}
+	if len(h.events) == 0 || finished != 1 {
+		t.Errorf("Expected the end of the download to be reported once. Got %+v", h.events)
+	}
+}
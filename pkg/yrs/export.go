@@ -0,0 +1,56 @@
+package yrs
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/tools/blog/atom"
+)
+
+// NewAtomFeed builds an Atom feed out of the given videos
+func NewAtomFeed(title, id string, videos []Video) *atom.Feed {
+	feed := &atom.Feed{
+		Title:   title,
+		ID:      id,
+		Updated: atom.Time(time.Now()),
+	}
+
+	feed.Entry = lo.Map(videos, func(v Video, _ int) *atom.Entry {
+		e := &atom.Entry{
+			Title:     v.Title,
+			ID:        v.ID,
+			Link:      []atom.Link{{Href: v.URL}},
+			Published: atom.Time(v.Published),
+			Updated:   atom.Time(v.Published),
+		}
+		if v.Channel != nil {
+			e.Author = &atom.Person{Name: v.Channel.Name}
+		}
+		return e
+	})
+
+	return feed
+}
+
+// WriteM3U writes the videos as an M3U playlist. Downloaded videos point to
+// their local file, the rest to their URL.
+func WriteM3U(w io.Writer, videos []Video) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+
+	for _, v := range videos {
+		title := v.Title
+		if v.Channel != nil {
+			title = fmt.Sprintf("%s - %s", v.Channel.Name, v.Title)
+		}
+
+		if _, err := fmt.Fprintf(w, "#EXTINF:-1,%s\n%s\n", title, v.Location()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
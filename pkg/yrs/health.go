@@ -0,0 +1,118 @@
+package yrs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrChannelsFailed is returned by Update, along with the new videos of the
+// rest, when the feeds of some channels couldn't be fetched
+var ErrChannelsFailed = errors.New("some channels couldn't be updated")
+
+// WithDisableAfter makes updates disable the channels whose feeds couldn't be
+// fetched that many times in a row, never disabling them when 0
+func WithDisableAfter(failures int) Option {
+	return func(y *Yrs) {
+		y.disableAfter = failures
+	}
+}
+
+// channelHealth is how fetching the feed of a channel went
+type channelHealth struct {
+	channel *Channel
+	err     error
+}
+
+// recordHealth records how fetching the feeds went for every channel, and
+// returns the ones disabled for failing too many times in a row. The counts
+// are kept by the database, as channels may be enabled or disabled while the
+// feeds are fetched.
+func (y *Yrs) recordHealth(results []channelHealth) ([]Channel, error) {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	disabled := make([]Channel, 0)
+	for _, r := range results {
+		c := *r.channel
+		if r.err == nil {
+			_, err := tx.Exec(
+				"UPDATE channels SET failures=0, last_error='', last_success=? WHERE id=?",
+				now, c.ID,
+			)
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("couldn't record the health of %s: %w", c.ID, err)
+			}
+			continue
+		}
+
+		c.LastError = r.err.Error()
+		err := tx.QueryRow(
+			"UPDATE channels SET failures=failures+1, last_error=? WHERE id=? RETURNING failures",
+			c.LastError, c.ID,
+		).Scan(&c.Failures)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Unsubscribed during the update
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("couldn't record the health of %s: %w", c.ID, err)
+		}
+		if y.disableAfter == 0 || c.Failures < y.disableAfter {
+			continue
+		}
+
+		// Only the channels that weren't disabled yet are told about
+		res, err := tx.Exec("UPDATE channels SET disabled=1 WHERE id=? AND NOT disabled", c.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("couldn't disable %s: %w", c.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			c.Disabled = true
+			disabled = append(disabled, c)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return disabled, nil
+}
+
+// SetEnabled enables or disables the given channel. Disabled channels are
+// skipped by the updates, and enabling them again forgets their failures.
+func (y *Yrs) SetEnabled(channelID string, enabled bool) error {
+	query := "UPDATE channels SET disabled=1 WHERE id=?"
+	if enabled {
+		query = "UPDATE channels SET disabled=0, failures=0, last_error='' WHERE id=?"
+	}
+
+	res, err := y.db.Exec(query, channelID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("channel %s not found", channelID)
+	}
+	return nil
+}
+
+// alertDisabled tells the notifiers that the channel was disabled
+func (y *Yrs) alertDisabled(c *Channel) error {
+	title := "Channel disabled: " + c.Name
+	text := fmt.Sprintf(
+		"%s was disabled after %d failed updates in a row: %s\n%s",
+		c.Name, c.Failures, c.LastError, c.RSS,
+	)
+	if err := y.alert(title, text); err != nil {
+		return fmt.Errorf("couldn't alert about %s being disabled: %w", c.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package yrs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingAlerter struct {
+	recordingNotifier
+	alerts []string
+}
+
+func (a *recordingAlerter) Alert(title, text string) error {
+	a.alerts = append(a.alerts, title+": "+text)
+	return nil
+}
+
+func TestChannelHealth(t *testing.T) {
+	srv := newFeedServer(t)
+	alerter := &recordingAlerter{}
+	disabled := &recordingHook{}
+	y := mustCreateYrs(t)
+	WithDisableAfter(2)(y)
+	WithNotifiers(alerter)(y)
+	y.Events().Subscribe(disabled, EventChannelDisabled)
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	getChannel := func() Channel {
+		t.Helper()
+		channels, err := y.GetChannels()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return channels[0]
+	}
+
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if c := getChannel(); c.Failures != 0 || c.LastSuccess == nil || c.Disabled {
+		t.Errorf("Expected a healthy channel. Got %+v", c)
+	}
+
+	srv.setFailing(true)
+	if _, err := y.Update(); !errors.Is(err, ErrChannelsFailed) {
+		t.Fatalf("Expected the channel to fail. Got %v", err)
+	}
+	if c := getChannel(); c.Failures != 1 || !strings.Contains(c.LastError, "404") || c.Disabled {
+		t.Errorf("Expected a failing channel. Got %+v", c)
+	}
+
+	if _, err := y.Update(); !errors.Is(err, ErrChannelsFailed) {
+		t.Fatalf("Expected the channel to fail. Got %v", err)
+	}
+	c := getChannel()
+	if c.Failures != 2 || !c.Disabled {
+		t.Errorf("Expected the channel to be disabled. Got %+v", c)
+	}
+	if len(disabled.events) != 1 || disabled.events[0].Channel.ID != c.ID {
+		t.Errorf("Expected the channel to be published as disabled. Got %+v", disabled.events)
+	}
+	if len(alerter.alerts) != 1 || !strings.Contains(alerter.alerts[0], "2 failed updates") {
+		t.Errorf("Expected an alert. Got %v", alerter.alerts)
+	}
+
+	// Disabled channels are skipped, and don't fail the updates anymore
+	before := srv.requestCount()
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.requestCount() != before {
+		t.Errorf("Expected the disabled channel to be skipped")
+	}
+
+	if err := y.SetEnabled(c.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if c := getChannel(); c.Failures != 0 || c.LastError != "" || c.Disabled {
+		t.Errorf("Expected the channel to be enabled again. Got %+v", c)
+	}
+	if err := y.SetEnabled("missing", true); err == nil {
+		t.Error("Expected an error enabling an unknown channel")
+	}
+}
+
+func TestPartialUpdate(t *testing.T) {
+	healthy := newFeedServer(t)
+	failing := newFeedServer(t)
+	y := mustCreateYrs(t)
+	for _, srv := range []*feedServer{healthy, failing} {
+		if err := y.Subscribe(srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	healthy.addItem("1", "one", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC))
+	failing.setFailing(true)
+	videos, err := y.Update()
+	if !errors.Is(err, ErrChannelsFailed) || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected the failing channel to be reported. Got %v", err)
+	}
+	if len(videos) != 1 || videos[0].Title != "one" {
+		t.Errorf("Expected the video of the healthy channel. Got %+v", videos)
+	}
+	if recorded, err := y.GetVideos(); err != nil || len(recorded) != 1 {
+		t.Errorf("Expected the video to be recorded. Got %+v, %v", recorded, err)
+	}
+
+	channels, err := y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range channels {
+		switch c.RSS {
+		case healthy.URL:
+			if c.Failures != 0 || c.LastSuccess == nil {
+				t.Errorf("Expected a healthy channel. Got %+v", c)
+			}
+		case failing.URL:
+			if c.Failures != 1 || c.LastSuccess != nil {
+				t.Errorf("Expected a failing channel. Got %+v", c)
+			}
+		}
+	}
+}
+
+func TestHealthKeepsToggles(t *testing.T) {
+	srv := newFeedServer(t)
+	y := mustCreateYrs(t)
+	WithDisableAfter(2)(y)
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The channels as they were when their feeds were fetched
+	fetched := channels[0]
+
+	// Disabled by hand while the feed was fetched
+	if err := y.SetEnabled(fetched.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.recordHealth([]channelHealth{{channel: &fetched}}); err != nil {
+		t.Fatal(err)
+	}
+	channels, err = y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := channels[0]; !c.Disabled || c.LastSuccess == nil {
+		t.Errorf("Expected the channel to stay disabled. Got %+v", c)
+	}
+
+	failure := []channelHealth{{channel: &fetched, err: errors.New("gone")}}
+	for range 2 {
+		disabled, err := y.recordHealth(failure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(disabled) != 0 {
+			t.Errorf("Expected a disabled channel not to be disabled again. Got %+v", disabled)
+		}
+	}
+	channels, err = y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := channels[0]; c.Failures != 2 || c.LastError != "gone" || !c.Disabled {
+		t.Errorf("Expected the failures to be counted from the database. Got %+v", c)
+	}
+}
@@ -0,0 +1,118 @@
+package yrs
+
+import (
+	"fmt"
+)
+
+// IndexReport tells how far the search index is from the videos it indexes
+type IndexReport struct {
+	// Missing are the videos that can't be found by searching
+	Missing int
+	// Stale are the entries of videos that don't exist anymore
+	Stale int
+	// Outdated are the entries whose title or channel name changed since
+	Outdated int
+}
+
+// Consistent tells whether the index matches the videos
+func (r IndexReport) Consistent() bool {
+	return r.Missing == 0 && r.Stale == 0 && r.Outdated == 0
+}
+
+// CheckSearchIndex compares the search index with the videos
+func (y *Yrs) CheckSearchIndex() (IndexReport, error) {
+	var r IndexReport
+	err := y.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM videos v WHERE v.id NOT IN (SELECT id FROM videos_fts)),
+			(SELECT COUNT(*) FROM videos_fts f WHERE f.id NOT IN (SELECT id FROM videos)),
+			(SELECT COUNT(*)
+			FROM videos_fts f
+			JOIN videos v ON (v.id=f.id)
+			JOIN channels c ON (c.id=v.channel_id)
+			WHERE f.title IS NOT v.title OR f.channel IS NOT c.name)
+	`).Scan(&r.Missing, &r.Stale, &r.Outdated)
+	if err != nil {
+		return r, fmt.Errorf("couldn't check the search index: %w", err)
+	}
+	return r, nil
+}
+
+// RebuildSearchIndex indexes every video again from scratch
+func (y *Yrs) RebuildSearchIndex() error {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM videos_fts",
+		`INSERT INTO videos_fts (id, title, channel)
+		SELECT v.id, v.title, c.name FROM videos v JOIN channels c ON (c.id=v.channel_id)`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("couldn't rebuild the search index: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// orphans selects, for every table, the rows pointing to something that
+// doesn't exist. Foreign keys prevent them, but only when enforced, which
+// older versions didn't.
+var orphans = []struct {
+	table string
+	where string
+}{
+	{"videos", "channel_id NOT IN (SELECT id FROM channels)"},
+	{"playlist_videos", "video_id NOT IN (SELECT id FROM videos) OR playlist NOT IN (SELECT name FROM playlists)"},
+	{"download_queue", "video_id NOT IN (SELECT id FROM videos)"},
+	{"websub", "channel_id NOT IN (SELECT id FROM channels)"},
+	{"pruned_videos", "channel_id NOT IN (SELECT id FROM channels)"},
+}
+
+// OrphanedRows counts the rows of every table pointing to something that
+// doesn't exist, leaving out the tables without any
+func (y *Yrs) OrphanedRows() (map[string]int, error) {
+	found := make(map[string]int)
+	for _, o := range orphans {
+		var n int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", o.table, o.where)
+		if err := y.db.QueryRow(query).Scan(&n); err != nil {
+			return nil, fmt.Errorf("couldn't look for orphaned rows in %s: %w", o.table, err)
+		}
+		if n > 0 {
+			found[o.table] = n
+		}
+	}
+	return found, nil
+}
+
+// DeleteOrphanedRows deletes the rows counted by OrphanedRows, returning how
+// many there were. The search index is left for RebuildSearchIndex.
+func (y *Yrs) DeleteOrphanedRows() (int64, error) {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, o := range orphans {
+		res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", o.table, o.where))
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("couldn't delete the orphaned rows of %s: %w", o.table, err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, tx.Commit()
+}
+
+// CheckFeed fetches and parses the feed of the channel, without recording
+// anything
+func (y *Yrs) CheckFeed(c Channel) error {
+	_, err := y.fetchFeed(c.RSS)
+	return err
+}
@@ -0,0 +1,136 @@
+package yrs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSearchIndexIntegrity(t *testing.T) {
+	y := mustCreateYrs(t)
+	if err := setupFixtures(y); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := y.CheckSearchIndex(); err != nil || !r.Consistent() {
+		t.Fatalf("Expected a consistent index. Got %+v, %v", r, err)
+	}
+
+	for _, query := range []string{
+		"UPDATE videos_fts SET title='old' WHERE title='title'",
+		"INSERT INTO videos_fts (id, title, channel) VALUES ('gone', 'gone', 'name')",
+	} {
+		if _, err := y.db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := y.CheckSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (IndexReport{Stale: 1, Outdated: 1}); r != exp {
+		t.Errorf("Unexpected report. Got %+v, Expected %+v", r, exp)
+	}
+
+	if err := y.RebuildSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := y.CheckSearchIndex(); err != nil || !r.Consistent() {
+		t.Errorf("Expected the rebuilt index to be consistent. Got %+v, %v", r, err)
+	}
+	results, err := y.Search("title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected to find the video after rebuilding. Got %v", results)
+	}
+}
+
+func TestOrphanedRows(t *testing.T) {
+	y := mustCreateYrs(t)
+	if err := setupFixtures(y); err != nil {
+		t.Fatal(err)
+	}
+
+	// Foreign keys have to be off for orphans to exist, which can only be
+	// done for a single connection
+	conn, err := y.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"PRAGMA foreign_keys=off",
+		"INSERT INTO download_queue (video_id, queued) VALUES ('gone', '2024-01-01')",
+		"INSERT INTO videos (id, url, title, published, channel_id, downloaded) VALUES ('lost', 'url', 'lost', '2024-01-01', 'gone', 0)",
+		"PRAGMA foreign_keys=on",
+	} {
+		if _, err := conn.ExecContext(context.Background(), query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	orphans, err := y.OrphanedRows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(orphans) != "map[download_queue:1 videos:1]" {
+		t.Errorf("Unexpected orphans %v", orphans)
+	}
+
+	deleted, err := y.DeleteOrphanedRows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("Unexpected deleted rows. Got %d, Expected 2", deleted)
+	}
+	if orphans, err := y.OrphanedRows(); err != nil || len(orphans) != 0 {
+		t.Errorf("Expected no orphans left. Got %v, %v", orphans, err)
+	}
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Errorf("Expected the fixture to be kept. Got %v", videos)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	dsn := "file:" + t.TempDir() + "/yrs.db"
+	if _, err := New("sqlite3", dsn); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := PendingMigrations("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations. Got %v", pending)
+	}
+	y, err := Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.GetChannels(); err != nil {
+		t.Errorf("Expected to query the database opened. Got %v", err)
+	}
+	if err := y.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := t.TempDir() + "/empty.db"
+	pending, err = PendingMigrations("sqlite3", "file:"+empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := migrationsFS.ReadDir("db/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(entries) {
+		t.Errorf("Expected every migration to be pending. Got %v", pending)
+	}
+}
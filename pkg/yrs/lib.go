@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -19,28 +20,105 @@ const (
 	rssFormat = "https://www.youtube.com/feeds/videos.xml?channel_id=%s"
 )
 
+const (
+	channelColumns = "c.id, c.url, c.name, c.rss, c.autodownload, c.provider, c.kind, " +
+		"c.include_shorts, c.include_live, c.failures, c.last_error, c.last_success, " +
+		"c.disabled"
+	videoColumns = "v.id, v.title, v.url, v.published, v.channel_id, v.downloaded, " +
+		"v.thumbnail, v.path, v.watched"
+)
+
 type Yrs struct {
-	db *sql.DB
+	db         *sql.DB
+	client     *http.Client
+	downloader string
+	notifiers  []Notifier
+	events     *EventBus
+	// disableAfter is the number of failed updates in a row disabling a
+	// channel, never when 0
+	disableAfter int
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func channelFields(c *Channel) []any {
+	return []any{
+		&c.ID, &c.URL, &c.Name, &c.RSS, &c.Autodownload, &c.Provider, &c.Kind,
+		&c.IncludeShorts, &c.IncludeLive, &c.Failures, &c.LastError, &c.LastSuccess,
+		&c.Disabled,
+	}
 }
 
-func New(driver, dsn string) (*Yrs, error) {
+func videoFields(v *Video) []any {
+	return []any{
+		&v.ID, &v.Title, &v.URL, &v.Published, &v.ChannelId, &v.Downloaded,
+		&v.Thumbnail, &v.Path, &v.Watched,
+	}
+}
+
+// scanVideo scans a row made of videoColumns followed by channelColumns
+func scanVideo(row scanner) (Video, error) {
+	v := Video{}
+	c := Channel{}
+	err := row.Scan(append(videoFields(&v), channelFields(&c)...)...)
+	v.Channel = &c
+	return v, err
+}
+
+// Option customizes the Yrs built by New or Open
+type Option func(*Yrs)
+
+// WithHTTPClient makes every request go through the given client, like one
+// built with NewHTTPClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(y *Yrs) {
+		y.client = c
+	}
+}
+
+func New(driver, dsn string, opts ...Option) (*Yrs, error) {
 	// Check if there's any schema migrations to run. That function is in
 	// charge of creating the db if it doesn't exist.
 	db, err := ManageSchema(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
+	return newYrs(db, opts), nil
+}
 
-	_, err = db.Exec("PRAGMA foreign_keys=on")
+// Open is like New, but leaves the pending migrations alone. Check
+// PendingMigrations first, as nothing works on an outdated schema.
+func Open(driver, dsn string, opts ...Option) (*Yrs, error) {
+	db, err := openDB(driver, dsn)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't enable foreign keys: %w", err)
+		return nil, err
+	}
+	return newYrs(db, opts), nil
+}
+
+func newYrs(db *sql.DB, opts []Option) *Yrs {
+	y := &Yrs{
+		db:         db,
+		client:     http.DefaultClient,
+		downloader: DefaultDownloader,
+		events:     &EventBus{},
 	}
+	for _, opt := range opts {
+		opt(y)
+	}
+	return y
+}
 
-	return &Yrs{db}, err
+// Close waits for the hooks to handle the events published so far, and
+// closes the database
+func (y *Yrs) Close() error {
+	return errors.Join(y.events.Close(), y.db.Close())
 }
 
 func (y *Yrs) forEachChannel(f func(*Channel) error) error {
-	rows, err := y.db.Query("SELECT * FROM channels")
+	rows, err := y.db.Query(fmt.Sprintf("SELECT %s FROM channels c", channelColumns))
 	if err != nil {
 		return fmt.Errorf("couldn't retrieve the channels: %w", err)
 	}
@@ -48,7 +126,7 @@ func (y *Yrs) forEachChannel(f func(*Channel) error) error {
 
 	for rows.Next() {
 		c := Channel{}
-		err = rows.Scan(&c.ID, &c.URL, &c.Name, &c.RSS, &c.Autodownload)
+		err = rows.Scan(channelFields(&c)...)
 		if err != nil {
 			return fmt.Errorf("scan failed: %w", err)
 		}
@@ -61,48 +139,26 @@ func (y *Yrs) forEachChannel(f func(*Channel) error) error {
 	return nil
 }
 
-func (y *Yrs) forEachVideo(f func(*Video)) error {
-	rows, err := y.db.Query(`
-		SELECT
-			v.id, v.title, v.url, v.published, v.channel_id, v.downloaded,
-			c.id, c.url, c.name, c.rss, c.autodownload
-		FROM videos v
-		JOIN channels c
-		ON (v.channel_id = c.id)
-		ORDER BY v.published
-	`)
-	if err != nil {
-		return fmt.Errorf("couldn't retrieve the videos: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		v := Video{}
-		c := Channel{}
-		err = rows.Scan(
-			&v.ID, &v.Title, &v.URL, &v.Published, &v.ChannelId, &v.Downloaded,
-			&c.ID, &c.URL, &c.Name, &c.RSS, &c.Autodownload,
-		)
-		v.Channel = &c
-		if err != nil {
-			return fmt.Errorf("scan failed: %w", err)
-		}
-		f(&v)
+func (y *Yrs) insertChannel(tx *sql.Tx, c Channel) error {
+	if c.Kind == "" {
+		c.Kind = KindChannel
 	}
 
-	return nil
-}
-
-func (y *Yrs) insertChannel(tx *sql.Tx, c Channel) error {
 	insert, err := tx.Prepare(`
-		INSERT INTO channels (id, url, name, rss, autodownload)
-		VALUES (?, ?, ?, ?, 0)
+		INSERT INTO channels (
+			id, url, name, rss, autodownload, provider, kind, include_shorts,
+			include_live
+		)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = insert.Exec(c.ID, c.URL, c.Name, c.RSS)
+	_, err = insert.Exec(
+		c.ID, c.URL, c.Name, c.RSS, c.Provider, c.Kind, c.IncludeShorts,
+		c.IncludeLive,
+	)
 	return err
 }
 
@@ -121,24 +177,20 @@ func (y *Yrs) GetChannels() ([]Channel, error) {
 }
 
 func (y *Yrs) GetVideos() ([]Video, error) {
-	rowSlice := make([]Video, 0)
-	err := y.forEachVideo(func(v *Video) {
-		rowSlice = append(rowSlice, *v)
-	})
-
+	videos, err := y.QueryVideos(VideoQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list videos: %w", err)
 	}
 
-	return rowSlice, err
+	return videos, nil
 }
 
 func (y *Yrs) SubscribeYouTubeID(channelStr string) error {
-	return y.Subscribe(fmt.Sprintf(rssFormat, channelStr))
+	return y.Subscribe(GetProvider(ProviderYouTube).FeedURL(channelStr))
 }
 
 func (y *Yrs) Subscribe(rss string) error {
-	feed, err := gofeed.NewParser().ParseURL(rss)
+	feed, err := y.fetchFeed(rss)
 	if err != nil {
 		return fmt.Errorf("error parsing RSS url %s: %w", rss, err)
 	}
@@ -147,15 +199,21 @@ func (y *Yrs) Subscribe(rss string) error {
 	s.Write([]byte(rss))
 
 	return y.subscribeChannel(Channel{
-		ID:           fmt.Sprintf("%x", s.Sum(nil))[:24],
-		URL:          feed.Link,
-		Name:         feed.Title,
-		RSS:          rss,
-		Autodownload: false,
+		ID:            fmt.Sprintf("%x", s.Sum(nil))[:24],
+		URL:           feed.Link,
+		Name:          feed.Title,
+		RSS:           rss,
+		Autodownload:  false,
+		Provider:      detectProvider(rss, feed).Name(),
+		Kind:          feedKind(rss),
+		IncludeShorts: true,
+		IncludeLive:   true,
 	}, feed)
 }
 
 func (y *Yrs) subscribeChannel(channel Channel, feed *gofeed.Feed) error {
+	filter := y.contentFilter(&channel)
+
 	tx, err := y.db.Begin()
 	if err != nil {
 		return fmt.Errorf("error on begin: %w", err)
@@ -166,78 +224,207 @@ func (y *Yrs) subscribeChannel(channel Channel, feed *gofeed.Feed) error {
 		return fmt.Errorf("error inserting channel: %w", err)
 	}
 
-	err = updateChannelVideos(tx, &channel, nil, feed)
+	err = updateChannelVideos(tx, &channel, nil, feed, filter)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("error updating channel videos: %w", err)
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	y.publish(Event{Type: EventChannelAdded, Channel: &channel})
+	return nil
 }
 
+// Update fetches the feeds of every enabled channel and records the new
+// videos, and then tells the notifiers about them. Only one update runs at a
+// time across every process using the same database, returning
+// ErrUpdateInProgress otherwise. The channels whose feeds couldn't be fetched
+// don't stop the rest from being recorded, returning ErrChannelsFailed along
+// with the new videos. The new videos and the failures are published to the
+// event bus, and the notifiers are alerted about the channels disabled for
+// failing too many times.
 func (y *Yrs) Update() ([]Video, error) {
-	errors_ := make(chan error, 100)
-	videos := make(chan Video, 100)
-	retErrors := make([]error, 0)
-	retVideos := make([]Video, 0)
+	found, failed, err := y.update()
+	for _, e := range failed {
+		y.publish(e)
+		if e.Type == EventChannelDisabled {
+			err = errors.Join(err, y.alertDisabled(e.Channel))
+		}
+	}
+	if err != nil && !errors.Is(err, ErrChannelsFailed) {
+		if !errors.Is(err, ErrUpdateInProgress) {
+			y.publish(Event{Type: EventUpdateFailed, Error: err.Error()})
+		}
+		return nil, err
+	}
 
-	tx, err := y.db.Begin()
-	if err != nil {
-		return retVideos, err
+	err = errors.Join(err, y.announce(found))
+	y.publish(Event{Type: EventUpdateFinished, Count: len(found)})
+	return found, err
+}
+
+// announce publishes and notifies the new videos
+func (y *Yrs) announce(found []Video) error {
+	for _, v := range found {
+		y.publish(Event{Type: EventVideoFound, Video: &v})
 	}
+	return y.notify(found)
+}
 
-	var wg sync.WaitGroup
-	err = y.forEachChannel(func(c *Channel) error {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			feed, e := gofeed.NewParser().ParseURL(c.RSS)
-			if e != nil {
-				errors_ <- fmt.Errorf("error retrieving %s: %s", c.RSS, e)
-				return
-			}
-			e = updateChannelVideos(tx, c, videos, feed)
-			if e != nil {
-				errors_ <- e
-			}
-		}()
-		return nil
-	})
+// channelFeed is a feed fetched for a channel, along with the filter of its
+// content and, when fetched by an update, the validators of its version
+type channelFeed struct {
+	channel    *Channel
+	feed       *gofeed.Feed
+	filter     *contentFilter
+	validators *validators
+}
 
+// recordVideos records the videos in the feeds, in a single transaction, and
+// returns the new ones
+func (y *Yrs) recordVideos(feeds []channelFeed) ([]Video, error) {
+	tx, err := y.db.Begin()
 	if err != nil {
-		tx.Rollback()
-		return retVideos, err
+		return nil, err
 	}
 
+	videos := make(chan Video)
+	collected := make(chan []Video)
 	go func() {
-		for e := range errors_ {
-			retErrors = append(retErrors, e)
-		}
-	}()
-
-	go func() {
+		found := make([]Video, 0)
 		for v := range videos {
-			retVideos = append(retVideos, v)
+			found = append(found, v)
 		}
+		collected <- found
 	}()
 
-	wg.Wait()
+	for _, f := range feeds {
+		if err = updateChannelVideos(tx, f.channel, videos, f.feed, f.filter); err != nil {
+			break
+		}
+		// Only now that it's recorded can the feed be skipped while it
+		// doesn't change
+		if f.validators != nil {
+			_, err = tx.Exec(
+				"UPDATE channels SET etag=?, last_modified=? WHERE id=?",
+				f.validators.etag, f.validators.lastModified, f.channel.ID,
+			)
+			if err != nil {
+				break
+			}
+		}
+	}
 	close(videos)
-	close(errors_)
+	found := <-collected
 
-	if len(retErrors) > 0 {
+	if err != nil {
 		tx.Rollback()
-		return retVideos, errors.Join(retErrors...)
+		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
 
-	err = tx.Commit()
-	return retVideos, err
+// update records the feeds fetched and returns their new videos, along with
+// the failures of the channels whose feeds couldn't be fetched and the
+// channels disabled because of them
+func (y *Yrs) update() ([]Video, []Event, error) {
+	unlock, err := y.lock(updateLock, ErrUpdateInProgress)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	// Every feed is fetched before writing anything, so that the database
+	// isn't locked for other writers while waiting on the network
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		feeds  = make([]channelFeed, 0)
+		errs   = make([]error, 0)
+		failed = make([]Event, 0)
+		health = make([]channelHealth, 0)
+	)
+	all, err := y.GetChannels()
+	if err != nil {
+		return nil, nil, err
+	}
+	channels := lo.Reject(all, func(c Channel, _ int) bool { return c.Disabled })
+	cached, err := y.feedValidators()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Progress is published as every channel is fetched
+	progress := Progress{Total: int64(len(channels))}
+	y.publish(Event{Type: EventUpdateProgress, Progress: &progress})
+	for _, c := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			feed, v, e := y.fetchFeedIfModified(c.RSS, cached[c.ID])
+			var filter *contentFilter
+			if e == nil {
+				filter = y.contentFilter(&c)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			progress.Done++
+			p := progress
+			event := Event{Type: EventUpdateProgress, Channel: &c, Progress: &p}
+			switch {
+			case errors.Is(e, errNotModified):
+				event.NotModified = true
+				health = append(health, channelHealth{channel: &c})
+			case e != nil:
+				event.Error = e.Error()
+				errs = append(errs, fmt.Errorf("error retrieving %s: %s", c.RSS, e))
+				failed = append(failed, Event{Type: EventUpdateFailed, Channel: &c, Error: e.Error()})
+				health = append(health, channelHealth{channel: &c, err: e})
+			default:
+				feeds = append(feeds, channelFeed{channel: &c, feed: feed, filter: filter, validators: &v})
+				health = append(health, channelHealth{channel: &c})
+			}
+			y.publish(event)
+		}()
+	}
+	wg.Wait()
+
+	// Only the feeds recorded count as a success for the health of their
+	// channels
+	found, err := y.recordVideos(feeds)
+	if err != nil {
+		return nil, nil, err
+	}
+	disabled, err := y.recordHealth(health)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range disabled {
+		failed = append(failed, Event{Type: EventChannelDisabled, Channel: &c, Error: c.LastError})
+	}
+
+	if len(errs) > 0 {
+		return found, failed, fmt.Errorf("%w: %w", ErrChannelsFailed, errors.Join(errs...))
+	}
+	return found, failed, nil
 }
 
-func updateChannelVideos(tx *sql.Tx, c *Channel, vc chan Video, feed *gofeed.Feed) error {
+func updateChannelVideos(
+	tx *sql.Tx,
+	c *Channel,
+	vc chan Video,
+	feed *gofeed.Feed,
+	filter *contentFilter,
+) error {
 	insert, err := tx.Prepare(
-		`INSERT INTO videos (id, url, title, published, channel_id, downloaded)
-		VALUES (?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO videos (id, url, title, published, channel_id, downloaded, thumbnail, found)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
 	)
 	if err != nil {
 		return err
@@ -250,33 +437,69 @@ func updateChannelVideos(tx *sql.Tx, c *Channel, vc chan Video, feed *gofeed.Fee
 		return err
 	}
 
+	// Videos first found through a playlist belong to the channel that
+	// published them, as soon as that one shows up in its own feed
+	reassign, err := tx.Prepare(`
+		UPDATE videos SET channel_id=?
+		WHERE id=? AND channel_id IN (SELECT id FROM channels WHERE kind=?)
+	`)
+	if err != nil {
+		return err
+	}
+
+	ftsReassign, err := tx.Prepare(`UPDATE videos_fts SET channel=? WHERE id=?`)
+	if err != nil {
+		return err
+	}
+
+	// Pruned videos stay in the feeds for a while, and aren't new again
+	pruned, err := tx.Prepare(`SELECT COUNT(*) FROM pruned_videos WHERE id=?`)
+	if err != nil {
+		return err
+	}
+
+	provider := GetProvider(c.Provider)
 	for _, item := range feed.Items {
-		date, err := parseDate(item.Published)
+		if filter.excludes(item) {
+			continue
+		}
+
+		v, err := provider.Video(item)
 		if err != nil {
-			return fmt.Errorf(
-				"error parsing date (%s) for video %s: %w",
-				item.Published,
-				item.Title,
-				err,
-			)
+			return err
+		}
+		v.ChannelId = c.ID
+		v.Channel = c
+
+		var wasPruned bool
+		if err := pruned.QueryRow(v.ID).Scan(&wasPruned); err != nil {
+			return err
 		}
-		v := Video{
-			ID:         getVideoID(item),
-			URL:        item.Link,
-			Title:      item.Title,
-			Published:  date,
-			ChannelId:  c.ID,
-			Downloaded: false,
-			Channel:    c,
+		if wasPruned {
+			continue
 		}
+
 		_, err = insert.Exec(
-			v.ID, v.URL, v.Title, v.Published, v.ChannelId, 0,
+			v.ID, v.URL, v.Title, v.Published, v.ChannelId, 0, v.Thumbnail,
+			time.Now().UTC(),
 		)
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) {
 			if !errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey) {
 				return err
 			}
+			if c.Kind == KindPlaylist {
+				continue
+			}
+			res, err := reassign.Exec(c.ID, v.ID, KindPlaylist)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				if _, err = ftsReassign.Exec(c.Name, v.ID); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
@@ -285,6 +508,12 @@ func updateChannelVideos(tx *sql.Tx, c *Channel, vc chan Video, feed *gofeed.Fee
 			return err
 		}
 
+		if c.Autodownload {
+			if err := queueDownload(tx, v.ID); err != nil {
+				return err
+			}
+		}
+
 		if vc != nil {
 			vc <- v
 		}
@@ -293,21 +522,153 @@ func updateChannelVideos(tx *sql.Tx, c *Channel, vc chan Video, feed *gofeed.Fee
 	return nil
 }
 
+func (y *Yrs) GetChannel(channelID string) (*Channel, error) {
+	row := y.db.QueryRow(
+		fmt.Sprintf("SELECT %s FROM channels c WHERE c.id=?", channelColumns),
+		channelID,
+	)
+
+	c := Channel{}
+	err := row.Scan(channelFields(&c)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("channel %s not found", channelID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// SetContentOptions chooses whether Shorts and live streams are recorded for
+// the given channel from now on
+func (y *Yrs) SetContentOptions(channelID string, includeShorts, includeLive bool) error {
+	res, err := y.db.Exec(
+		"UPDATE channels SET include_shorts=?, include_live=? WHERE id=?",
+		includeShorts,
+		includeLive,
+		channelID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("channel %s not found", channelID)
+	}
+	return nil
+}
+
+// FindChannels returns the channels matching s exactly by ID, URL, feed URL
+// or name. YouTube channel URLs and IDs are matched against the feed of the
+// channel. When nothing matches exactly, channels whose name or ID start with
+// s are returned instead.
+func (y *Yrs) FindChannels(s string) ([]Channel, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty channel")
+	}
+
+	rss := s
+	if channelIDRe.MatchString(s) {
+		rss = fmt.Sprintf(rssFormat, s)
+	} else if id := channelIDFromURL(withScheme(s), ""); id != "" {
+		rss = fmt.Sprintf(rssFormat, id)
+	}
+
+	for _, cond := range []struct {
+		where string
+		args  []any
+	}{
+		{
+			where: "c.id=? OR c.url=? OR c.rss=? OR c.name=? COLLATE NOCASE",
+			args:  []any{s, s, rss, s},
+		},
+		{
+			where: "lower(substr(c.name, 1, length(?)))=lower(?) OR substr(c.id, 1, length(?))=?",
+			args:  []any{s, s, s, s},
+		},
+	} {
+		rows, err := y.db.Query(fmt.Sprintf(
+			"SELECT %s FROM channels c WHERE %s ORDER BY c.name",
+			channelColumns,
+			cond.where,
+		), cond.args...)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't retrieve the channels: %w", err)
+		}
+
+		channels := make([]Channel, 0)
+		for rows.Next() {
+			c := Channel{}
+			if err := rows.Scan(channelFields(&c)...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan failed: %w", err)
+			}
+			channels = append(channels, c)
+		}
+		rows.Close()
+
+		if len(channels) > 0 {
+			return channels, nil
+		}
+	}
+
+	return []Channel{}, nil
+}
+
+// Unsubscribe deletes the channel along with its videos, including them
+// being in playlists or in the download queue. Files already downloaded are
+// kept.
 func (y *Yrs) Unsubscribe(channelID string) error {
-	delete, err := y.db.Prepare("DELETE FROM channels WHERE id=?")
+	channel, err := y.GetChannel(channelID)
 	if err != nil {
 		return err
 	}
 
-	_, err = delete.Exec(channelID)
-	return err
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM videos_fts WHERE id IN (SELECT id FROM videos WHERE channel_id=?)",
+		"DELETE FROM playlist_videos WHERE video_id IN (SELECT id FROM videos WHERE channel_id=?)",
+		"DELETE FROM download_queue WHERE video_id IN (SELECT id FROM videos WHERE channel_id=?)",
+		"DELETE FROM videos WHERE channel_id=?",
+		"DELETE FROM pruned_videos WHERE channel_id=?",
+	} {
+		if _, err := tx.Exec(query, channelID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error unsubscribing from %s: %w", channelID, err)
+		}
+	}
+
+	res, err := tx.Exec("DELETE FROM channels WHERE id=?", channelID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error unsubscribing from %s: %w", channelID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return fmt.Errorf("channel %s not found", channelID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	y.publish(Event{Type: EventChannelRemoved, Channel: channel})
+	return nil
 }
 
 func (y *Yrs) Search(s string) ([]SearchResult, error) {
-	rows, err := y.db.Query(
-		"SELECT * FROM videos_fts WHERE videos_fts MATCH ? ORDER BY rank",
-		s,
-	)
+	rows, err := y.db.Query(`
+		SELECT f.id, f.title, f.channel, v.url
+		FROM videos_fts f
+		JOIN videos v ON (f.id=v.id)
+		WHERE videos_fts MATCH ?
+		ORDER BY rank
+	`, s)
 	if err != nil {
 		return nil, err
 	}
@@ -316,7 +677,7 @@ func (y *Yrs) Search(s string) ([]SearchResult, error) {
 	results := make([]SearchResult, 0)
 	for rows.Next() {
 		res := SearchResult{}
-		err = rows.Scan(&res.ID, &res.Title, &res.Channel)
+		err = rows.Scan(&res.ID, &res.Title, &res.Channel, &res.URL)
 		if err != nil {
 			return nil, fmt.Errorf("scan failed: %w", err)
 		}
@@ -328,9 +689,7 @@ func (y *Yrs) Search(s string) ([]SearchResult, error) {
 
 func (y *Yrs) GetVideosByID(ids []string) ([]Video, error) {
 	query := `
-		SELECT
-			v.id, v.title, v.url, v.published, v.channel_id, v.downloaded,
-			c.id, c.url, c.name, c.rss, c.autodownload
+		SELECT %s, %s
 		FROM videos v
 		JOIN channels c ON (v.channel_id=c.id)
 		WHERE v.id IN (%s)
@@ -340,67 +699,118 @@ func (y *Yrs) GetVideosByID(ids []string) ([]Video, error) {
 	s := lo.Map(ids, func(item string, index int) interface{} {
 		return interface{}(&item)
 	})
-	rows, err := y.db.Query(fmt.Sprintf(query, placeholders), s...)
+	rows, err := y.db.Query(
+		fmt.Sprintf(query, videoColumns, channelColumns, placeholders),
+		s...,
+	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	videos := make([]Video, 0)
 	for rows.Next() {
-		v := Video{}
-		c := Channel{}
-		err = rows.Scan(
-			&v.ID, &v.Title, &v.URL, &v.Published, &v.ChannelId, &v.Downloaded,
-			&c.ID, &c.URL, &c.Name, &c.RSS, &c.Autodownload,
-		)
+		v, err := scanVideo(rows)
 		if err != nil {
 			return nil, err
 		}
-		v.Channel = &c
 		videos = append(videos, v)
 	}
 
 	return videos, nil
 }
 
+// GetVideosByChannel returns the videos of the channel with the given name
+// or ID
 func (y *Yrs) GetVideosByChannel(ch string) ([]Video, error) {
-	query := `
-		SELECT
-			v.id, v.title, v.url, v.published, v.channel_id, v.downloaded,
-			c.id, c.url, c.name, c.rss, c.autodownload
-		FROM videos v
-		JOIN channels c ON (v.channel_id=c.id)
-		WHERE c.name=?
-		ORDER BY v.published
-	`
-	log.Printf("Listing videos for channel %s", ch)
-	rows, err := y.db.Query(query, ch)
+	return y.QueryVideos(VideoQuery{Channel: ch})
+}
+
+// feedKind tells playlist feeds apart from the ones of channels
+func feedKind(rss string) string {
+	u, err := url.Parse(rss)
+	if err == nil && u.Query().Has("playlist_id") {
+		return KindPlaylist
+	}
+	return KindChannel
+}
+
+func (y *Yrs) fetchFeed(rss string) (*gofeed.Feed, error) {
+	p := gofeed.NewParser()
+	p.Client = y.client
+	return p.ParseURL(rss)
+}
+
+// errNotModified is returned by fetchFeedIfModified for the feeds that didn't
+// change since they were last recorded
+var errNotModified = errors.New("feed not modified")
+
+// validators identify a version of a feed, for conditional requests
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// feedValidators returns the validators of the feeds last recorded, by the ID
+// of their channel
+func (y *Yrs) feedValidators() (map[string]validators, error) {
+	rows, err := y.db.Query("SELECT id, etag, last_modified FROM channels")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("couldn't get the validators of the feeds: %w", err)
 	}
+	defer rows.Close()
 
-	videos := make([]Video, 0)
+	byChannel := make(map[string]validators)
 	for rows.Next() {
-		v := Video{}
-		c := Channel{}
-		err := rows.Scan(
-			&v.ID, &v.Title, &v.URL, &v.Published, &v.ChannelId, &v.Downloaded,
-			&c.ID, &c.URL, &c.Name, &c.RSS, &c.Autodownload,
-		)
-		if err != nil {
-			return nil, err
+		var id string
+		var v validators
+		if err := rows.Scan(&id, &v.etag, &v.lastModified); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
 		}
-		v.Channel = &c
-		videos = append(videos, v)
+		byChannel[id] = v
 	}
-
-	return videos, nil
+	return byChannel, rows.Err()
 }
 
-func (y *Yrs) DeleteChannel(ch string) error {
-	query := "DELETE FROM channels WHERE id=?"
-	_, err := y.db.Exec(query, ch)
-	return err
+// fetchFeedIfModified fetches the feed unless it didn't change since the
+// version identified by cached, returning errNotModified then. The validators
+// of the new version are returned, to be kept once it's recorded.
+func (y *Yrs) fetchFeedIfModified(rss string, cached validators) (*gofeed.Feed, validators, error) {
+	// Sent like fetchFeed does, unless the client replaces it
+	p := gofeed.NewParser()
+	req, err := http.NewRequest(http.MethodGet, rss, nil)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	res, err := y.client.Do(req)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, cached, errNotModified
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, validators{}, gofeed.HTTPError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+
+	feed, err := p.Parse(res.Body)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	return feed, validators{
+		etag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+	}, nil
 }
 
 func parseDate(dateStr string) (time.Time, error) {
@@ -422,7 +832,14 @@ func parseDate(dateStr string) (time.Time, error) {
 }
 
 func getVideoID(item *gofeed.Item) string {
+	// Some podcast feeds don't link their episodes anywhere, so fall back to
+	// the GUID in those
+	key := item.Link
+	if key == "" {
+		key = item.GUID
+	}
+
 	s := sha1.New()
-	s.Write([]byte(item.Link))
+	s.Write([]byte(key))
 	return fmt.Sprintf("%x", s.Sum(nil))[:10]
 }
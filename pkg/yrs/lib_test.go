@@ -2,9 +2,13 @@ package yrs
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mmcdole/gofeed"
 	ext "github.com/mmcdole/gofeed/extensions"
@@ -33,6 +37,55 @@ func mustCreateYrs(t *testing.T) *Yrs {
 	return y
 }
 
+// feedServer serves an RSS feed that tests can add items to, or make fail
+// with a 404, counting the requests
+type feedServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	items    string
+	fail     bool
+	requests int
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	s := &feedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		if s.fail {
+			http.Error(w, "gone", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>feed</title>%s</channel></rss>`, s.items)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// addItem publishes a video, with the given ID in its link and GUID
+func (s *feedServer) addItem(id, title string, published time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items += fmt.Sprintf(
+		`<item><title>%s</title><link>https://example.org/%s</link><guid>%s</guid><pubDate>%s</pubDate></item>`,
+		title, id, id, published.Format(time.RFC1123Z),
+	)
+}
+
+func (s *feedServer) setFailing(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *feedServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
 func setupFixtures(y *Yrs) error {
 	feed := &gofeed.Feed{
 		Items: []*gofeed.Item{
@@ -144,3 +197,208 @@ func TestSearch(t *testing.T) {
 		t.Fatalf("Unexpected search result. Got %s, Expected {videoId title name}", r)
 	}
 }
+
+func TestPlaylistDedup(t *testing.T) {
+	y := mustCreateYrs(t)
+
+	feed := &gofeed.Feed{
+		Items: []*gofeed.Item{
+			{Published: "2006-01-02T15:04:05Z", Title: "title", Link: "link"},
+		},
+	}
+
+	err := y.subscribeChannel(Channel{
+		ID:   "playlist",
+		Name: "playlist name",
+		RSS:  "playlist rss",
+		Kind: KindPlaylist,
+	}, feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = y.subscribeChannel(Channel{
+		ID:   "channel",
+		Name: "channel name",
+		RSS:  "channel rss",
+		Kind: KindChannel,
+	}, feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(videos) != 1 {
+		t.Fatalf("Unexpected number of videos. Got %d, Expected %d", len(videos), 1)
+	}
+
+	if videos[0].ChannelId != "channel" {
+		t.Errorf("Unexpected video owner. Got: %s Expected: %s", videos[0].ChannelId, "channel")
+	}
+
+	r, err := y.Search("title")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r) != 1 || r[0].Channel != "channel name" {
+		t.Errorf("Unexpected search results. Got: %v", r)
+	}
+}
+
+func TestFindChannels(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupQueryFixtures(t, y)
+
+	ytID := "UC5NO8MgTQKHAWXp6z8Xl7yQ"
+	err := y.subscribeChannel(Channel{
+		ID:   "yt-id",
+		URL:  "https://www.youtube.com/channel/" + ytID,
+		Name: "firstborn",
+		RSS:  fmt.Sprintf(rssFormat, ytID),
+	}, &gofeed.Feed{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		s   string
+		exp []string
+	}{
+		{s: "first", exp: []string{"first"}},
+		{s: "Second", exp: []string{"second"}},
+		{s: "second-id", exp: []string{"second"}},
+		{s: "fir", exp: []string{"first", "firstborn"}},
+		{s: "sec", exp: []string{"second"}},
+		{s: ytID, exp: []string{"firstborn"}},
+		{s: "youtube.com/channel/" + ytID + "/videos", exp: []string{"firstborn"}},
+		{s: "third", exp: []string{}},
+		{s: "%", exp: []string{}},
+	}
+
+	for _, test := range testCases {
+		channels, err := y.FindChannels(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := make([]string, 0)
+		for _, c := range channels {
+			names = append(names, c.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(test.exp) {
+			t.Errorf("Unexpected channels for %q. Got %v, Expected %v", test.s, names, test.exp)
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupQueryFixtures(t, y)
+
+	videos, err := y.GetVideosByChannel("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := y.AddToPlaylist(WatchLater, videos[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.QueueDownload(videos[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := y.Unsubscribe("first-id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.Unsubscribe("first-id"); err == nil {
+		t.Error("Expected an error unsubscribing twice")
+	}
+
+	for table, exp := range map[string]int{
+		"channels":        1,
+		"videos":          3,
+		"videos_fts":      3,
+		"playlist_videos": 0,
+		"download_queue":  0,
+	} {
+		var got int
+		if err := y.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != exp {
+			t.Errorf("Unexpected rows in %s. Got %d, Expected %d", table, got, exp)
+		}
+	}
+}
+
+func TestConditionalUpdate(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>feed</title><item><title>one</title><link>https://example.org/1</link><guid>1</guid><pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate></item></channel></rss>`)
+	}))
+	defer srv.Close()
+
+	dsn := "file:" + t.TempDir() + "/yrs.db"
+	y, err := New("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The validators are kept across processes
+	y, err = New("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	h := &recordingHook{}
+	y.Events().Subscribe(h, EventUpdateProgress)
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	last := h.events[len(h.events)-1]
+	if last.Channel == nil || last.Error != "" || !last.NotModified {
+		t.Errorf("Expected the feed not to be modified. Got %+v", last)
+	}
+	if requests != 3 {
+		t.Errorf("Unexpected requests. Got %d, Expected 3", requests)
+	}
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Errorf("Expected the video to be kept. Got %v", videos)
+	}
+	channels, err := y.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := channels[0]; c.Failures != 0 || c.LastSuccess == nil {
+		t.Errorf("Expected the feed not modified to count as a success. Got %+v", c)
+	}
+}
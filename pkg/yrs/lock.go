@@ -0,0 +1,123 @@
+package yrs
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const updateLock = "update"
+
+// lockTTL is how long locks last unless refreshed, so that the ones held by
+// processes that died are eventually released
+var lockTTL = time.Minute
+
+// ErrUpdateInProgress is returned by Update when another one is running,
+// either in this process or in any other using the same database
+var ErrUpdateInProgress = errors.New("update already in progress")
+
+// Lock is held in the database by whoever is running a task that can't run
+// concurrently, like an update
+type Lock struct {
+	Name     string    `json:"name"`
+	Holder   string    `json:"holder"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// holder identifies this process in the locks it takes
+func holder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s (PID %d)", host, os.Getpid())
+}
+
+// lock takes the named lock, returning busy if someone else holds it. The lock
+// is refreshed in the background until released with the returned function.
+func (y *Yrs) lock(name string, busy error) (func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now().UTC()
+	res, err := y.db.Exec(`
+		INSERT INTO locks (name, token, holder, acquired, expires)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			token=excluded.token,
+			holder=excluded.holder,
+			acquired=excluded.acquired,
+			expires=excluded.expires
+		WHERE julianday(locks.expires) < julianday(excluded.acquired)
+	`, name, token, holder(), now, now.Add(lockTTL))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't take the %s lock: %w", name, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		l, err := y.GetLock(name)
+		if err != nil || l == nil {
+			return nil, busy
+		}
+		return nil, fmt.Errorf(
+			"%w, started by %s at %s",
+			busy, l.Holder, l.Acquired.Local().Format(time.DateTime),
+		)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				y.db.Exec(
+					"UPDATE locks SET expires=? WHERE name=? AND token=?",
+					time.Now().UTC().Add(lockTTL), name, token,
+				)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		y.db.Exec("DELETE FROM locks WHERE name=? AND token=?", name, token)
+	}, nil
+}
+
+// GetLock returns the named lock, or nil if nobody holds it
+func (y *Yrs) GetLock(name string) (*Lock, error) {
+	l := Lock{}
+	err := y.db.QueryRow(`
+		SELECT name, holder, acquired, expires FROM locks
+		WHERE name=? AND julianday(expires) >= julianday(?)
+	`, name, time.Now().UTC().Format(time.DateTime)).Scan(&l.Name, &l.Holder, &l.Acquired, &l.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the %s lock: %w", name, err)
+	}
+	return &l, nil
+}
+
+// UpdateLock returns the lock of the update in progress, or nil if there's
+// none running
+func (y *Yrs) UpdateLock() (*Lock, error) {
+	return y.GetLock(updateLock)
+}
@@ -0,0 +1,91 @@
+package yrs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateLock(t *testing.T) {
+	// Two instances on the same database, like two processes would be
+	dsn := fmt.Sprintf("file:%s/yrs.db", t.TempDir())
+	first, err := New("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := New("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := first.lock(updateLock, ErrUpdateInProgress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := second.Update(); !errors.Is(err, ErrUpdateInProgress) {
+		t.Errorf("Unexpected error updating while locked. Got %v, Expected %v", err, ErrUpdateInProgress)
+	}
+	l, err := second.UpdateLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l == nil || l.Holder != holder() {
+		t.Errorf("Unexpected lock. Got %+v, Expected one held by %s", l, holder())
+	}
+
+	unlock()
+	if l, err := second.UpdateLock(); err != nil || l != nil {
+		t.Errorf("Expected the lock to be released. Got %+v, %v", l, err)
+	}
+	if _, err := second.Update(); err != nil {
+		t.Errorf("Unexpected error updating after the lock was released: %s", err)
+	}
+
+	// Locks of processes that died are taken over once expired
+	past := time.Now().UTC().Add(-time.Hour)
+	_, err = first.db.Exec(
+		"INSERT INTO locks (name, token, holder, acquired, expires) VALUES (?, ?, ?, ?, ?)",
+		updateLock, "dead", "dead", past, past.Add(lockTTL),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, err := second.UpdateLock(); err != nil || l != nil {
+		t.Errorf("Expected the expired lock to be ignored. Got %+v, %v", l, err)
+	}
+	unlock, err = second.lock(updateLock, ErrUpdateInProgress)
+	if err != nil {
+		t.Errorf("Expected the expired lock to be taken over. Got %s", err)
+	} else {
+		unlock()
+	}
+}
+
+func TestWithPragmas(t *testing.T) {
+	dsn := withPragmas("file:/tmp/yrs.db?_busy_timeout=100&cache=shared")
+	base, query, _ := strings.Cut(dsn, "?")
+	if base != "file:/tmp/yrs.db" {
+		t.Errorf("Unexpected path. Got %s, Expected %s", base, "file:/tmp/yrs.db")
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]string{
+		"_busy_timeout": "100",
+		"_foreign_keys": "on",
+		"_journal_mode": "WAL",
+		"_txlock":       "immediate",
+		"cache":         "shared",
+	}
+	for k, v := range exp {
+		if values.Get(k) != v {
+			t.Errorf("Unexpected value for %s. Got %q, Expected %q", k, values.Get(k), v)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package yrs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultDesktopNotifier is the command showing desktop notifications
+const DefaultDesktopNotifier = "notify-send"
+
+// ErrNotification is returned by Update, along with the new videos, when they
+// were recorded but some notifier failed to tell about them
+var ErrNotification = errors.New("couldn't send notifications")
+
+// Notifier tells about the new videos found by Update
+type Notifier interface {
+	Notify(videos []Video) error
+}
+
+// WithNotifiers makes Update tell the given notifiers about new videos
+func WithNotifiers(n ...Notifier) Option {
+	return func(y *Yrs) {
+		y.notifiers = append(y.notifiers, n...)
+	}
+}
+
+// Alerter tells about problems needing attention, like channels disabled for
+// failing too many times. Every notifier is an Alerter.
+type Alerter interface {
+	Alert(title, text string) error
+}
+
+// alert tells the notifiers able to about a problem
+func (y *Yrs) alert(title, text string) error {
+	errs := make([]error, 0)
+	for _, n := range y.notifiers {
+		if a, ok := n.(Alerter); ok {
+			if err := a.Alert(title, text); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (y *Yrs) notify(videos []Video) error {
+	if len(videos) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0)
+	for _, n := range y.notifiers {
+		if err := n.Notify(videos); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrNotification, errors.Join(errs...))
+	}
+	return nil
+}
+
+type channelFilter struct {
+	next     Notifier
+	channels []string
+}
+
+// FilterChannels only passes on to n the videos of the given channels, by ID
+// or by name
+func FilterChannels(n Notifier, channels ...string) Notifier {
+	return &channelFilter{next: n, channels: channels}
+}
+
+func (f *channelFilter) Notify(videos []Video) error {
+	selected := make([]Video, 0)
+	for _, v := range videos {
+		if slices.Contains(f.channels, v.ChannelId) ||
+			(v.Channel != nil && slices.ContainsFunc(f.channels, func(c string) bool {
+				return strings.EqualFold(c, v.Channel.Name)
+			})) {
+			selected = append(selected, v)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return f.next.Notify(selected)
+}
+
+// Alert passes alerts on regardless of the channels
+func (f *channelFilter) Alert(title, text string) error {
+	if a, ok := f.next.(Alerter); ok {
+		return a.Alert(title, text)
+	}
+	return nil
+}
+
+// summary returns the title and the text of the notifications
+func summary(videos []Video) (string, string) {
+	title := fmt.Sprintf("%d new videos", len(videos))
+	if len(videos) == 1 {
+		title = "New video"
+		if v := videos[0]; v.Channel != nil {
+			title = "New video from " + v.Channel.Name
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range videos {
+		if v.Channel != nil && len(videos) > 1 {
+			fmt.Fprintf(&b, "%s: ", v.Channel.Name)
+		}
+		fmt.Fprintf(&b, "%s\n%s\n", v.Title, v.URL)
+	}
+	return title, strings.TrimSuffix(b.String(), "\n")
+}
+
+// SMTPNotifier sends an email, using STARTTLS when the server supports it
+type SMTPNotifier struct {
+	// Address of the server, like smtp.example.org:587
+	Address string
+	// Username and Password authenticate with the server, when set
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *SMTPNotifier) Notify(videos []Video) error {
+	title, text := summary(videos)
+	return n.send(title, text, "")
+}
+
+func (n *SMTPNotifier) Alert(title, text string) error {
+	return n.send(title, text, "")
+}
+
+// send sends an email with the given subject and text, along with an HTML
+// version of it when given
+func (n *SMTPNotifier) send(subject, text, html string) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, _ := strings.Cut(n.Address, ":")
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+
+	if html == "" {
+		h := partHeader("text/plain")
+		for _, k := range slices.Sorted(maps.Keys(h)) {
+			fmt.Fprintf(&msg, "%s: %s\r\n", k, h.Get(k))
+		}
+		msg.WriteString("\r\n")
+		if err := writeQuoted(&msg, text); err != nil {
+			return err
+		}
+	} else {
+		mw := multipart.NewWriter(&msg)
+		fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain", text},
+			{"text/html", html},
+		} {
+			w, err := mw.CreatePart(partHeader(part.contentType))
+			if err != nil {
+				return err
+			}
+			if err := writeQuoted(w, part.body); err != nil {
+				return err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := smtp.SendMail(n.Address, auth, n.From, n.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("error sending email through %s: %w", n.Address, err)
+	}
+	return nil
+}
+
+// partHeader returns the headers of a part of an email, which is quoted so
+// that long lines and non ASCII characters go through
+func partHeader(contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	}
+}
+
+func writeQuoted(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, strings.ReplaceAll(body, "\n", "\r\n")); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// WebhookNotifier posts the new videos as JSON, like {"videos": [...]}, to a
+// URL. Alerts are posted like {"alert": {"title": "...", "text": "..."}}.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(videos []Video) error {
+	return n.post(map[string][]Video{"videos": videos})
+}
+
+func (n *WebhookNotifier) Alert(title, text string) error {
+	return n.post(map[string]map[string]string{"alert": {"title": title, "text": text}})
+}
+
+func (n *WebhookNotifier) post(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return post(n.Client, req)
+}
+
+// NtfyNotifier publishes a push notification to an ntfy topic, like
+// https://ntfy.sh/my-videos
+type NtfyNotifier struct {
+	URL string
+	// Token is the access token for protected topics
+	Token  string
+	Client *http.Client
+}
+
+func (n *NtfyNotifier) Notify(videos []Video) error {
+	title, text := summary(videos)
+	click := ""
+	if len(videos) == 1 {
+		click = videos[0].URL
+	}
+	return n.publish(title, text, click)
+}
+
+func (n *NtfyNotifier) Alert(title, text string) error {
+	return n.publish(title, text, "")
+}
+
+// publish publishes a message to the topic, opening click when tapped if set
+func (n *NtfyNotifier) publish(title, text, click string) error {
+	req, err := http.NewRequest(http.MethodPost, n.URL, strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", title))
+	if click != "" {
+		req.Header.Set("Click", click)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(n.Client, req)
+}
+
+// GotifyNotifier sends a push notification through a Gotify server, like
+// https://gotify.example.org
+type GotifyNotifier struct {
+	URL string
+	// Token is the one of the application sending the messages
+	Token  string
+	Client *http.Client
+}
+
+func (n *GotifyNotifier) Notify(videos []Video) error {
+	return n.send(summary(videos))
+}
+
+func (n *GotifyNotifier) Alert(title, text string) error {
+	return n.send(title, text)
+}
+
+func (n *GotifyNotifier) send(title, text string) error {
+	body, err := json.Marshal(map[string]any{
+		"title":    title,
+		"message":  text,
+		"priority": 5,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		strings.TrimSuffix(n.URL, "/")+"/message",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", n.Token)
+	return post(n.Client, req)
+}
+
+// post sends the request of a notifier, failing unless it's accepted
+func post(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error notifying %s: %w", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("error notifying %s: %s", req.URL.Host, res.Status)
+	}
+	return nil
+}
+
+// DesktopNotifier shows a desktop notification, running notify-send or the
+// given command with the title and the text of the notification appended
+type DesktopNotifier struct {
+	Command []string
+}
+
+func (n *DesktopNotifier) Notify(videos []Video) error {
+	return n.show(summary(videos))
+}
+
+func (n *DesktopNotifier) Alert(title, text string) error {
+	return n.show(title, text)
+}
+
+func (n *DesktopNotifier) show(title, text string) error {
+	command := n.Command
+	if len(command) == 0 {
+		command = []string{DefaultDesktopNotifier}
+	}
+
+	args := append(slices.Clone(command[1:]), title, text)
+	if out, err := exec.Command(command[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("error running %s: %w: %s", command[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
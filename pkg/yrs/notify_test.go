@@ -0,0 +1,235 @@
+package yrs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	videos []Video
+}
+
+func (n *recordingNotifier) Notify(videos []Video) error {
+	n.videos = append(n.videos, videos...)
+	return nil
+}
+
+func notifyFixtures() []Video {
+	first := &Channel{ID: "first-id", Name: "First"}
+	second := &Channel{ID: "second-id", Name: "Second"}
+	return []Video{
+		{ID: "1", Title: "one", URL: "https://example.org/1", ChannelId: first.ID, Channel: first},
+		{ID: "2", Title: "two", URL: "https://example.org/2", ChannelId: second.ID, Channel: second},
+	}
+}
+
+func TestUpdateNotifies(t *testing.T) {
+	srv := newFeedServer(t)
+	srv.addItem("1", "one", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	n := &recordingNotifier{}
+	y := mustCreateYrs(t)
+	WithNotifiers(n)(y)
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.addItem("2", "two", time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC))
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.videos) != 1 || n.videos[0].Title != "two" {
+		t.Errorf("Unexpected notification. Got %+v, Expected one for video two", n.videos)
+	}
+
+	if _, err := y.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.videos) != 1 {
+		t.Errorf("Unexpected notification without new videos. Got %+v", n.videos[1:])
+	}
+}
+
+func TestFilterChannels(t *testing.T) {
+	testCases := []struct {
+		channels []string
+		exp      []string
+	}{
+		{channels: []string{"first-id"}, exp: []string{"one"}},
+		{channels: []string{"second"}, exp: []string{"two"}},
+		{channels: []string{"First", "second-id"}, exp: []string{"one", "two"}},
+		{channels: []string{"third"}, exp: []string{}},
+	}
+
+	for _, test := range testCases {
+		n := &recordingNotifier{}
+		if err := FilterChannels(n, test.channels...).Notify(notifyFixtures()); err != nil {
+			t.Fatal(err)
+		}
+
+		titles := make([]string, 0)
+		for _, v := range n.videos {
+			titles = append(titles, v.Title)
+		}
+		if strings.Join(titles, ",") != strings.Join(test.exp, ",") {
+			t.Errorf("Unexpected videos for %v. Got %v, Expected %v", test.channels, titles, test.exp)
+		}
+	}
+}
+
+func TestHTTPNotifiers(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	videos := notifyFixtures()
+
+	if err := (&WebhookNotifier{URL: srv.URL + "/hook"}).Notify(videos); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct{ Videos []Video }
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Videos) != 2 || payload.Videos[1].Channel.Name != "Second" {
+		t.Errorf("Unexpected webhook payload %s", body)
+	}
+
+	if err := (&NtfyNotifier{URL: srv.URL + "/videos", Token: "tk"}).Notify(videos[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/videos" || req.Header.Get("Title") != "New video from First" ||
+		req.Header.Get("Click") != "https://example.org/1" || req.Header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("Unexpected ntfy request to %s with headers %v", req.URL, req.Header)
+	}
+	if exp := "one\nhttps://example.org/1"; string(body) != exp {
+		t.Errorf("Unexpected ntfy message. Got %q, Expected %q", body, exp)
+	}
+
+	if err := (&GotifyNotifier{URL: srv.URL + "/", Token: "app"}).Notify(videos); err != nil {
+		t.Fatal(err)
+	}
+	var message map[string]any
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/message" || req.Header.Get("X-Gotify-Key") != "app" || message["title"] != "2 new videos" {
+		t.Errorf("Unexpected gotify request to %s with %s", req.URL, body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := (&WebhookNotifier{URL: failing.URL}).Notify(videos); err == nil {
+		t.Error("Expected an error from a rejected webhook")
+	}
+}
+
+// serveSMTP accepts a single message, returning the channel it's sent to once
+// received
+func serveSMTP(t *testing.T) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					fmt.Fprint(conn, "250 OK\r\n")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case "DATA":
+				inData = true
+				fmt.Fprint(conn, "354 Go ahead\r\n")
+			case "QUIT":
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := serveSMTP(t)
+	n := &SMTPNotifier{Address: addr, From: "yrs@example.org", To: []string{"me@example.org"}}
+	if err := n.Notify(notifyFixtures()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-received
+	for _, exp := range []string{
+		"To: me@example.org\r\n",
+		"Subject: 2 new videos\r\n",
+		"First: one\r\nhttps://example.org/1\r\n",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("Expected %q in the email. Got %q", exp, msg)
+		}
+	}
+}
+
+func TestDesktopNotifier(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "notification")
+	n := &DesktopNotifier{Command: []string{"sh", "-c", `printf '%s|%s' "$1" "$2" > "$0"`, out}}
+	if err := n.Notify(notifyFixtures()[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "New video from Second|two\nhttps://example.org/2"; string(content) != exp {
+		t.Errorf("Unexpected notification. Got %q, Expected %q", content, exp)
+	}
+
+	if err := (&DesktopNotifier{Command: []string{"false"}}).Notify(notifyFixtures()); err == nil {
+		t.Error("Expected an error from a failing command")
+	}
+}
@@ -0,0 +1,66 @@
+package yrs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+)
+
+// DefaultPlayer is the external player used when none is configured
+const DefaultPlayer = "mpv"
+
+// Player launches videos in an external program
+type Player struct {
+	// Command is the program followed by its arguments. The location of the
+	// video is appended. Defaults to DefaultPlayer.
+	Command []string
+	// WatchedOnSuccess only marks videos as watched when the player exits
+	// successfully
+	WatchedOnSuccess bool
+}
+
+// Play opens the downloaded file of the video or, if it wasn't downloaded,
+// its URL in the player, and waits for it to exit. The player takes over the
+// terminal meanwhile. The video is marked as watched afterwards.
+func (y *Yrs) Play(v Video, p Player) error {
+	command := p.Command
+	if len(command) == 0 {
+		command = []string{DefaultPlayer}
+	}
+
+	args := append(slices.Clone(command[1:]), v.Location())
+	cmd := exec.Command(command[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		err = fmt.Errorf("error playing %s with %s: %w", v.ID, command[0], err)
+		if p.WatchedOnSuccess {
+			return err
+		}
+	}
+
+	return errors.Join(err, y.SetWatched(true, v.ID))
+}
+
+// NextUnwatched returns the oldest video not watched yet, optionally only
+// among the ones of the given channel
+func (y *Yrs) NextUnwatched(channel string) (*Video, error) {
+	videos, err := y.QueryVideos(VideoQuery{
+		Channel:   channel,
+		Unwatched: true,
+		Sort:      SortOldest,
+		Limit:     1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(videos) == 0 {
+		return nil, errors.New("no unwatched videos left")
+	}
+	return &videos[0], nil
+}
@@ -0,0 +1,48 @@
+package yrs
+
+import (
+	"testing"
+)
+
+func TestPlay(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupQueryFixtures(t, y)
+
+	testCases := []struct {
+		player  Player
+		err     bool
+		watched bool
+	}{
+		{player: Player{Command: []string{"true"}}, watched: true},
+		{player: Player{Command: []string{"false"}}, err: true, watched: true},
+		{player: Player{Command: []string{"false"}, WatchedOnSuccess: true}, err: true},
+		{player: Player{Command: []string{"sh", "-c", `test "$0" = "first link 2"`}, WatchedOnSuccess: true}, watched: true},
+	}
+
+	for _, test := range testCases {
+		v, err := y.NextUnwatched("first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Title != "first 2" {
+			t.Fatalf("Unexpected next video. Got %s, Expected %s", v.Title, "first 2")
+		}
+
+		err = y.Play(*v, test.player)
+		if (err != nil) != test.err {
+			t.Errorf("Unexpected error playing with %v: %v", test.player.Command, err)
+		}
+
+		videos, err := y.GetVideosByID([]string{v.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if videos[0].Watched != test.watched {
+			t.Errorf("Unexpected watched state with %v. Got %t, Expected %t", test.player.Command, videos[0].Watched, test.watched)
+		}
+
+		if err := y.SetWatched(false, v.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
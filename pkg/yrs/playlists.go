@@ -0,0 +1,298 @@
+package yrs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WatchLater is the playlist created along with the database
+const WatchLater = "watch-later"
+
+func (y *Yrs) CreatePlaylist(name string, autodownload bool) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("playlists need a name")
+	}
+
+	_, err := y.db.Exec(
+		"INSERT INTO playlists (name, autodownload) VALUES (?, ?)",
+		name,
+		autodownload,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating playlist %s: %w", name, err)
+	}
+	return nil
+}
+
+func (y *Yrs) DeletePlaylist(name string) error {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM playlist_videos WHERE playlist=?", name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := tx.Exec("DELETE FROM playlists WHERE name=?", name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return fmt.Errorf("playlist %s not found", name)
+	}
+
+	return tx.Commit()
+}
+
+func (y *Yrs) SetPlaylistAutodownload(name string, autodownload bool) error {
+	res, err := y.db.Exec(
+		"UPDATE playlists SET autodownload=? WHERE name=?",
+		autodownload,
+		name,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("playlist %s not found", name)
+	}
+	return nil
+}
+
+func (y *Yrs) GetPlaylists() ([]Playlist, error) {
+	rows, err := y.db.Query(`
+		SELECT p.name, p.autodownload, COUNT(pv.video_id)
+		FROM playlists p
+		LEFT JOIN playlist_videos pv ON (pv.playlist=p.name)
+		GROUP BY p.name
+		ORDER BY p.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't retrieve the playlists: %w", err)
+	}
+	defer rows.Close()
+
+	playlists := make([]Playlist, 0)
+	for rows.Next() {
+		p := Playlist{}
+		if err := rows.Scan(&p.Name, &p.Autodownload, &p.Videos); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		playlists = append(playlists, p)
+	}
+
+	return playlists, nil
+}
+
+func (y *Yrs) GetPlaylist(name string) (*Playlist, error) {
+	p := Playlist{}
+	err := y.db.QueryRow(`
+		SELECT p.name, p.autodownload, COUNT(pv.video_id)
+		FROM playlists p
+		LEFT JOIN playlist_videos pv ON (pv.playlist=p.name)
+		WHERE p.name=?
+		GROUP BY p.name
+	`, name).Scan(&p.Name, &p.Autodownload, &p.Videos)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("playlist %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPlaylistVideos returns the videos in the playlist, in order
+func (y *Yrs) GetPlaylistVideos(name string) ([]Video, error) {
+	if _, err := y.GetPlaylist(name); err != nil {
+		return nil, err
+	}
+
+	rows, err := y.db.Query(fmt.Sprintf(`
+		SELECT %s, %s
+		FROM playlist_videos pv
+		JOIN videos v ON (pv.video_id=v.id)
+		JOIN channels c ON (v.channel_id=c.id)
+		WHERE pv.playlist=?
+		ORDER BY pv.position
+	`, videoColumns, channelColumns), name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := make([]Video, 0)
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		videos = append(videos, v)
+	}
+
+	return videos, nil
+}
+
+// AddToPlaylist appends the videos to the end of the playlist, skipping the
+// ones already in it. Videos added to playlists with autodownload enabled
+// are queued for download.
+func (y *Yrs) AddToPlaylist(name string, videoIDs ...string) error {
+	p, err := y.GetPlaylist(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	for _, id := range videoIDs {
+		res, err := tx.Exec(`
+			INSERT INTO playlist_videos (playlist, video_id, position)
+			SELECT ?, v.id, (
+				SELECT COALESCE(MAX(position), 0) + 1
+				FROM playlist_videos WHERE playlist=?
+			)
+			FROM videos v
+			WHERE v.id=?
+			ON CONFLICT DO NOTHING
+		`, name, name, id)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error adding %s to playlist %s: %w", id, name, err)
+		}
+
+		var exists bool
+		if n, _ := res.RowsAffected(); n == 0 {
+			err := tx.QueryRow("SELECT 1 FROM videos WHERE id=?", id).Scan(&exists)
+			if errors.Is(err, sql.ErrNoRows) {
+				tx.Rollback()
+				return fmt.Errorf("video %s not found", id)
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if p.Autodownload {
+			if err := queueDownload(tx, id); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (y *Yrs) RemoveFromPlaylist(name, videoID string) error {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	var position int
+	err = tx.QueryRow(
+		"SELECT position FROM playlist_videos WHERE playlist=? AND video_id=?",
+		name,
+		videoID,
+	).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return fmt.Errorf("video %s not found in playlist %s", videoID, name)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM playlist_videos WHERE playlist=? AND video_id=?",
+		name,
+		videoID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE playlist_videos SET position=position-1 WHERE playlist=? AND position>?",
+		name,
+		position,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveInPlaylist moves the video to the given position, starting at 1, and
+// shifts the videos in between
+func (y *Yrs) MoveInPlaylist(name, videoID string, position int) error {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	var current, last int
+	err = tx.QueryRow(`
+		SELECT position, (SELECT MAX(position) FROM playlist_videos WHERE playlist=?)
+		FROM playlist_videos
+		WHERE playlist=? AND video_id=?
+	`, name, name, videoID).Scan(&current, &last)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return fmt.Errorf("video %s not found in playlist %s", videoID, name)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	position = max(1, min(position, last))
+	if position == current {
+		tx.Rollback()
+		return nil
+	}
+
+	if position < current {
+		_, err = tx.Exec(`
+			UPDATE playlist_videos SET position=position+1
+			WHERE playlist=? AND position>=? AND position<?
+		`, name, position, current)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE playlist_videos SET position=position-1
+			WHERE playlist=? AND position>? AND position<=?
+		`, name, current, position)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE playlist_videos SET position=? WHERE playlist=? AND video_id=?",
+		position,
+		name,
+		videoID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,154 @@
+package yrs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/samber/lo"
+)
+
+func setupPlaylistFixtures(t *testing.T, y *Yrs) []string {
+	t.Helper()
+
+	items := make([]*gofeed.Item, 0)
+	for i := range 4 {
+		items = append(items, &gofeed.Item{
+			Published: fmt.Sprintf("2006-01-0%dT15:04:05Z", i+1),
+			Title:     fmt.Sprintf("title %d", i),
+			Link:      fmt.Sprintf("link %d", i),
+		})
+	}
+
+	err := y.subscribeChannel(Channel{ID: "id", Name: "name", RSS: "rss"}, &gofeed.Feed{Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return lo.Map(videos, func(v Video, _ int) string { return v.ID })
+}
+
+func playlistTitles(t *testing.T, y *Yrs, name string) []string {
+	t.Helper()
+
+	videos, err := y.GetPlaylistVideos(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lo.Map(videos, func(v Video, _ int) string { return v.Title })
+}
+
+func TestPlaylists(t *testing.T) {
+	y := mustCreateYrs(t)
+	ids := setupPlaylistFixtures(t, y)
+
+	playlists, err := y.GetPlaylists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlists) != 1 || playlists[0].Name != WatchLater {
+		t.Fatalf("Unexpected default playlists. Got %v", playlists)
+	}
+
+	if err := y.AddToPlaylist(WatchLater, ids...); err != nil {
+		t.Fatal(err)
+	}
+	// Adding twice keeps a single entry
+	if err := y.AddToPlaylist(WatchLater, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.AddToPlaylist(WatchLater, "missing"); err == nil {
+		t.Errorf("Expected error adding a missing video")
+	}
+
+	exp := "title 0,title 1,title 2,title 3"
+	if got := strings.Join(playlistTitles(t, y, WatchLater), ","); got != exp {
+		t.Errorf("Unexpected playlist. Got: %s Expected: %s", got, exp)
+	}
+
+	if err := y.MoveInPlaylist(WatchLater, ids[3], 1); err != nil {
+		t.Fatal(err)
+	}
+	exp = "title 3,title 0,title 1,title 2"
+	if got := strings.Join(playlistTitles(t, y, WatchLater), ","); got != exp {
+		t.Errorf("Unexpected playlist after moving up. Got: %s Expected: %s", got, exp)
+	}
+
+	if err := y.MoveInPlaylist(WatchLater, ids[0], 10); err != nil {
+		t.Fatal(err)
+	}
+	exp = "title 3,title 1,title 2,title 0"
+	if got := strings.Join(playlistTitles(t, y, WatchLater), ","); got != exp {
+		t.Errorf("Unexpected playlist after moving down. Got: %s Expected: %s", got, exp)
+	}
+
+	if err := y.RemoveFromPlaylist(WatchLater, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.MoveInPlaylist(WatchLater, ids[0], 1); err != nil {
+		t.Fatal(err)
+	}
+	exp = "title 0,title 3,title 2"
+	if got := strings.Join(playlistTitles(t, y, WatchLater), ","); got != exp {
+		t.Errorf("Unexpected playlist after removing. Got: %s Expected: %s", got, exp)
+	}
+
+	pruned, err := y.Prune(RetentionPolicy{KeepLast: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].ID != ids[1] {
+		t.Errorf("Expected only the video out of the playlist to be pruned. Got: %v", pruned)
+	}
+
+	if err := y.DeletePlaylist(WatchLater); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.GetPlaylistVideos(WatchLater); err == nil {
+		t.Errorf("Expected error listing a deleted playlist")
+	}
+}
+
+func TestPlaylistAutodownload(t *testing.T) {
+	y := mustCreateYrs(t)
+	ids := setupPlaylistFixtures(t, y)
+
+	if err := y.CreatePlaylist("weekend", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.AddToPlaylist("weekend", ids[0], ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := y.GetDownloadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 2 {
+		t.Errorf("Unexpected download queue length. Got %d, Expected %d", len(queue), 2)
+	}
+}
+
+func TestWriteM3U(t *testing.T) {
+	videos := []Video{
+		{Title: "title", URL: "url", Channel: &Channel{Name: "name"}},
+		{Title: "local", URL: "url", Downloaded: true, Path: "/tmp/local.mp4"},
+	}
+
+	var b bytes.Buffer
+	if err := WriteM3U(&b, videos); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "#EXTM3U\n#EXTINF:-1,name - title\nurl\n#EXTINF:-1,local\n/tmp/local.mp4\n"
+	if b.String() != exp {
+		t.Errorf("Unexpected M3U. Got: %q Expected: %q", b.String(), exp)
+	}
+}
@@ -0,0 +1,251 @@
+package yrs
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	ProviderYouTube  = "youtube"
+	ProviderPeerTube = "peertube"
+	ProviderRSS      = "rss"
+)
+
+// DownloadHints tell a downloader how to fetch a given video
+type DownloadHints struct {
+	// URL is what has to be handed over to the downloader
+	URL string
+	// Direct is true when URL points to the media file itself, so it can be
+	// fetched without an external tool like yt-dlp
+	Direct bool
+	// Args are extra arguments for the external downloader
+	Args []string
+}
+
+// Provider encapsulates everything that depends on the platform publishing a
+// feed: how its feeds look like, how videos are identified and where they can
+// be watched or downloaded from.
+type Provider interface {
+	// Name is the identifier stored along with each channel
+	Name() string
+	// Match reports whether the feed served from rss belongs to this provider
+	Match(rss string, feed *gofeed.Feed) bool
+	// FeedURL builds the feed URL for the given platform specific channel ID
+	FeedURL(channelID string) string
+	// Video maps a feed item into a Video
+	Video(item *gofeed.Item) (Video, error)
+	// DownloadHints returns how the given video should be downloaded
+	DownloadHints(v Video) DownloadHints
+}
+
+// providers is sorted by precedence. The generic RSS one goes last as it
+// matches anything.
+var providers = []Provider{
+	youTubeProvider{},
+	peerTubeProvider{},
+	rssProvider{},
+}
+
+// GetProvider returns the provider registered under the given name. Channels
+// stored without one are handled as generic RSS feeds.
+func GetProvider(name string) Provider {
+	for _, p := range providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return rssProvider{}
+}
+
+func detectProvider(rss string, feed *gofeed.Feed) Provider {
+	for _, p := range providers {
+		if p.Match(rss, feed) {
+			return p
+		}
+	}
+	return rssProvider{}
+}
+
+// baseVideo fills in the fields every provider maps the same way
+func baseVideo(item *gofeed.Item) (Video, error) {
+	date, err := parseDate(item.Published)
+	if err != nil {
+		return Video{}, fmt.Errorf(
+			"error parsing date (%s) for video %s: %w",
+			item.Published,
+			item.Title,
+			err,
+		)
+	}
+
+	v := Video{
+		ID:        getVideoID(item),
+		URL:       item.Link,
+		Title:     item.Title,
+		Published: date,
+	}
+	if item.Image != nil {
+		v.Thumbnail = item.Image.URL
+	}
+
+	return v, nil
+}
+
+// mediaThumbnail looks for a Media RSS thumbnail, either directly in the item
+// or inside a media:group, which is where YouTube puts it
+func mediaThumbnail(item *gofeed.Item) string {
+	media, ok := item.Extensions["media"]
+	if !ok {
+		return ""
+	}
+
+	if thumbs := media["thumbnail"]; len(thumbs) > 0 {
+		return thumbs[0].Attrs["url"]
+	}
+
+	for _, group := range media["group"] {
+		if thumbs := group.Children["thumbnail"]; len(thumbs) > 0 {
+			return thumbs[0].Attrs["url"]
+		}
+	}
+
+	return ""
+}
+
+// mediaVideo maps items from feeds using Media RSS, like the ones from YouTube
+// and PeerTube
+func mediaVideo(item *gofeed.Item) (Video, error) {
+	v, err := baseVideo(item)
+	if err != nil {
+		return v, err
+	}
+	if v.Thumbnail == "" {
+		v.Thumbnail = mediaThumbnail(item)
+	}
+	return v, nil
+}
+
+type youTubeProvider struct{}
+
+func (youTubeProvider) Name() string { return ProviderYouTube }
+
+func (youTubeProvider) Match(rss string, _ *gofeed.Feed) bool {
+	u, err := url.Parse(rss)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	return host == "youtube.com" && u.Path == "/feeds/videos.xml"
+}
+
+func (youTubeProvider) FeedURL(channelID string) string {
+	return fmt.Sprintf(rssFormat, channelID)
+}
+
+// The content pushed through WebSub has no thumbnails, but YouTube serves
+// them at a well known URL
+func (youTubeProvider) Video(item *gofeed.Item) (Video, error) {
+	v, err := mediaVideo(item)
+	if err == nil && v.Thumbnail == "" {
+		if ids := item.Extensions["yt"]["videoId"]; len(ids) > 0 {
+			v.Thumbnail = fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", ids[0].Value)
+		}
+	}
+	return v, err
+}
+
+func (youTubeProvider) DownloadHints(v Video) DownloadHints {
+	return DownloadHints{URL: v.URL}
+}
+
+type peerTubeProvider struct{}
+
+func (peerTubeProvider) Name() string { return ProviderPeerTube }
+
+// PeerTube instances serve their feeds from /feeds/videos.xml, filtered by
+// either a videoChannelId or an accountId
+func (peerTubeProvider) Match(rss string, feed *gofeed.Feed) bool {
+	if feed != nil && strings.Contains(strings.ToLower(feed.Generator), "peertube") {
+		return true
+	}
+
+	u, err := url.Parse(rss)
+	if err != nil || u.Path != "/feeds/videos.xml" {
+		return false
+	}
+	q := u.Query()
+	return q.Has("videoChannelId") || q.Has("accountId") ||
+		q.Has("videoChannelName") || q.Has("accountName")
+}
+
+// FeedURL expects the channel ID in the form "<instance host>/<channel id>"
+func (peerTubeProvider) FeedURL(channelID string) string {
+	host, id, _ := strings.Cut(channelID, "/")
+	return fmt.Sprintf("https://%s/feeds/videos.xml?videoChannelId=%s", host, id)
+}
+
+func (peerTubeProvider) Video(item *gofeed.Item) (Video, error) {
+	return mediaVideo(item)
+}
+
+func (peerTubeProvider) DownloadHints(v Video) DownloadHints {
+	return DownloadHints{URL: v.URL}
+}
+
+// rssProvider handles any other RSS or Atom feed, like podcasts, where the
+// media is usually published as an enclosure
+type rssProvider struct{}
+
+func (rssProvider) Name() string { return ProviderRSS }
+
+func (rssProvider) Match(_ string, _ *gofeed.Feed) bool { return true }
+
+func (rssProvider) FeedURL(channelID string) string { return channelID }
+
+func (rssProvider) Video(item *gofeed.Item) (Video, error) {
+	v, err := baseVideo(item)
+	if err != nil {
+		return v, err
+	}
+
+	// Podcasts link to a web page about the episode, but what we are after
+	// is the media itself
+	for _, e := range item.Enclosures {
+		if isMediaType(e.Type) {
+			v.URL = e.URL
+			break
+		}
+	}
+
+	if v.Thumbnail == "" {
+		v.Thumbnail = mediaThumbnail(item)
+	}
+
+	return v, nil
+}
+
+func (rssProvider) DownloadHints(v Video) DownloadHints {
+	return DownloadHints{URL: v.URL, Direct: isMediaFile(v.URL)}
+}
+
+func isMediaType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "audio/") ||
+		strings.HasPrefix(mimeType, "video/")
+}
+
+func isMediaFile(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".mp3", ".m4a", ".ogg", ".opus", ".mp4", ".m4v", ".webm", ".mkv":
+		return true
+	}
+	return false
+}
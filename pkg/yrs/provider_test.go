@@ -0,0 +1,91 @@
+package yrs
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+func TestDetectProvider(t *testing.T) {
+	testCases := []struct {
+		rss  string
+		feed *gofeed.Feed
+		exp  string
+	}{
+		{
+			rss: "https://www.youtube.com/feeds/videos.xml?channel_id=UC5NO8MgTQKHAWXp6z8Xl7yQ",
+			exp: ProviderYouTube,
+		},
+		{
+			rss: "https://framatube.org/feeds/videos.xml?videoChannelId=1",
+			exp: ProviderPeerTube,
+		},
+		{
+			rss:  "https://videos.example.org/channel.xml",
+			feed: &gofeed.Feed{Generator: "PeerTube - https://videos.example.org"},
+			exp:  ProviderPeerTube,
+		},
+		{
+			rss: "https://odysee.com/$/rss/@someone:1",
+			exp: ProviderRSS,
+		},
+		{
+			rss: "https://example.org/podcast.rss",
+			exp: ProviderRSS,
+		},
+	}
+
+	for _, test := range testCases {
+		feed := test.feed
+		if feed == nil {
+			feed = &gofeed.Feed{}
+		}
+		got := detectProvider(test.rss, feed).Name()
+		if got != test.exp {
+			t.Errorf("Unexpected provider for %s. Got: %s Expected: %s", test.rss, got, test.exp)
+		}
+	}
+}
+
+func TestProviderVideo(t *testing.T) {
+	youtubeItem := &gofeed.Item{
+		Published: "2006-01-02T15:04:05Z",
+		Title:     "title",
+		Link:      "https://www.youtube.com/watch?v=videoId",
+		Extensions: ext.Extensions{"media": map[string][]ext.Extension{
+			"group": {{Children: map[string][]ext.Extension{
+				"thumbnail": {{Attrs: map[string]string{"url": "thumb.jpg"}}},
+			}}},
+		}},
+	}
+
+	v, err := GetProvider(ProviderYouTube).Video(youtubeItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.URL != youtubeItem.Link || v.Thumbnail != "thumb.jpg" {
+		t.Errorf("Unexpected YouTube video. Got: %+v", v)
+	}
+
+	podcastItem := &gofeed.Item{
+		Published: "Mon, 02 Jan 2006 15:04:05 -0700",
+		Title:     "episode",
+		Link:      "https://example.org/episode",
+		Enclosures: []*gofeed.Enclosure{
+			{URL: "https://example.org/episode.mp3", Type: "audio/mpeg"},
+		},
+	}
+
+	p := GetProvider(ProviderRSS)
+	v, err = p.Video(podcastItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.URL != "https://example.org/episode.mp3" {
+		t.Errorf("Unexpected podcast URL. Got: %s", v.URL)
+	}
+	if !p.DownloadHints(v).Direct {
+		t.Errorf("Expected podcast episode to be downloaded directly")
+	}
+}
@@ -0,0 +1,124 @@
+package yrs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy decides which videos are worth keeping around. Videos are
+// pruned when they fall out of the last KeepLast of their channel, or when
+// they are older than MaxAge. Zero values disable each of the limits.
+// Downloaded videos, as well as the ones in a playlist or waiting to be
+// downloaded, are always kept.
+type RetentionPolicy struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.MaxAge <= 0
+}
+
+// Prune deletes the videos outside of the retention policy and returns them.
+// With dryRun, the videos are only returned. The pruned videos are remembered,
+// so that the updates don't find them again while they're still in the feeds.
+func (y *Yrs) Prune(p RetentionPolicy, dryRun bool) ([]Video, error) {
+	if p.IsZero() {
+		return nil, errors.New("empty retention policy, nothing to prune")
+	}
+
+	keepLast := p.KeepLast
+	if keepLast <= 0 {
+		keepLast = -1
+	}
+	cutoff := time.Time{}
+	if p.MaxAge > 0 {
+		cutoff = time.Now().Add(-p.MaxAge)
+	}
+
+	// The videos are picked in the same transaction that deletes them, so
+	// that an update can't slip in between
+	tx, err := y.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error on begin: %w", err)
+	}
+
+	videos, err := pruneCandidates(tx, keepLast, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if dryRun || len(videos) == 0 {
+		tx.Rollback()
+		return videos, nil
+	}
+
+	remember, err := tx.Prepare(
+		"INSERT OR IGNORE INTO pruned_videos (id, channel_id, pruned) VALUES (?, ?, ?)",
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, v := range videos {
+		if _, err := remember.Exec(v.ID, v.ChannelId, now); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("error pruning video %s: %w", v.ID, err)
+		}
+	}
+
+	for _, query := range []string{
+		"DELETE FROM videos_fts WHERE id=?",
+		"DELETE FROM videos WHERE id=?",
+	} {
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		for _, v := range videos {
+			if _, err := stmt.Exec(v.ID); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("error pruning video %s: %w", v.ID, err)
+			}
+		}
+	}
+
+	return videos, tx.Commit()
+}
+
+func pruneCandidates(tx *sql.Tx, keepLast int, cutoff time.Time) ([]Video, error) {
+	rows, err := tx.Query(fmt.Sprintf(`
+		SELECT %s, %s
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (
+				PARTITION BY channel_id ORDER BY julianday(published) DESC
+			) AS n
+			FROM videos
+		) v
+		JOIN channels c ON (v.channel_id=c.id)
+		WHERE v.downloaded=0
+		AND v.id NOT IN (SELECT video_id FROM playlist_videos)
+		AND v.id NOT IN (SELECT video_id FROM download_queue)
+		AND ((? > 0 AND v.n > ?) OR julianday(v.published) < julianday(?))
+		ORDER BY julianday(v.published)
+	`, videoColumns, channelColumns), keepLast, keepLast, cutoff.UTC().Format(time.DateTime))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't retrieve the videos to prune: %w", err)
+	}
+	defer rows.Close()
+
+	videos := make([]Video, 0)
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		videos = append(videos, v)
+	}
+	return videos, rows.Err()
+}
@@ -0,0 +1,141 @@
+package yrs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func setupPruneFixtures(t *testing.T, y *Yrs) {
+	t.Helper()
+
+	items := make([]*gofeed.Item, 0)
+	for i := range 5 {
+		items = append(items, &gofeed.Item{
+			Published: time.Now().AddDate(0, 0, -10*i).Format(time.RFC3339),
+			Title:     fmt.Sprintf("title %d", i),
+			Link:      fmt.Sprintf("link %d", i),
+		})
+	}
+
+	err := y.subscribeChannel(Channel{
+		ID:   "id",
+		Name: "name",
+		RSS:  "rss",
+	}, &gofeed.Feed{Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testCases := []struct {
+		policy RetentionPolicy
+		exp    int
+	}{
+		{policy: RetentionPolicy{KeepLast: 2}, exp: 3},
+		{policy: RetentionPolicy{MaxAge: 15 * 24 * time.Hour}, exp: 3},
+		{policy: RetentionPolicy{KeepLast: 4, MaxAge: 25 * 24 * time.Hour}, exp: 2},
+		{policy: RetentionPolicy{KeepLast: 10}, exp: 0},
+	}
+
+	for _, test := range testCases {
+		y := mustCreateYrs(t)
+		setupPruneFixtures(t, y)
+
+		dry, err := y.Prune(test.policy, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dry) != test.exp {
+			t.Errorf("Unexpected number of videos to prune for %+v. Got %d, Expected %d", test.policy, len(dry), test.exp)
+		}
+
+		videos, err := y.GetVideos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 5 {
+			t.Errorf("Dry run deleted videos. Got %d, Expected %d", len(videos), 5)
+		}
+
+		pruned, err := y.Prune(test.policy, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		videos, err = y.GetVideos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(videos) != 5-len(pruned) || len(pruned) != test.exp {
+			t.Errorf("Unexpected number of videos left. Got %d, Expected %d", len(videos), 5-test.exp)
+		}
+
+		results, err := y.Search("title")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ftsRows int
+		if err := y.db.QueryRow("SELECT COUNT(*) FROM videos_fts").Scan(&ftsRows); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(videos) || ftsRows != len(videos) {
+			t.Errorf("FTS rows not pruned. Got %d, Expected %d", ftsRows, len(videos))
+		}
+	}
+}
+
+func TestPruneKeepsDownloaded(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupPruneFixtures(t, y)
+
+	if _, err := y.db.Exec("UPDATE videos SET downloaded=1 WHERE title='title 4'"); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := y.Prune(RetentionPolicy{KeepLast: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pruned) != 3 {
+		t.Errorf("Unexpected number of pruned videos. Got %d, Expected %d", len(pruned), 3)
+	}
+}
+
+func TestPrunedVideosAreNotFoundAgain(t *testing.T) {
+	srv := newFeedServer(t)
+	for i := range 5 {
+		srv.addItem(fmt.Sprint(i), fmt.Sprintf("title %d", i), time.Now().AddDate(0, 0, -10*i))
+	}
+
+	y := mustCreateYrs(t)
+	if err := y.Subscribe(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := y.Prune(RetentionPolicy{KeepLast: 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 3 {
+		t.Fatalf("Unexpected number of pruned videos. Got %d, Expected %d", len(pruned), 3)
+	}
+
+	found, err := y.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Errorf("Expected the pruned videos not to be found again. Got %v", found)
+	}
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 {
+		t.Errorf("Unexpected number of videos left. Got %d, Expected %d", len(videos), 2)
+	}
+}
@@ -0,0 +1,152 @@
+package yrs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SortOldest  = "oldest"
+	SortNewest  = "newest"
+	SortTitle   = "title"
+	SortChannel = "channel"
+)
+
+var videoSorts = map[string]string{
+	SortOldest:  "julianday(v.published), v.id",
+	SortNewest:  "julianday(v.published) DESC, v.id",
+	SortTitle:   "v.title COLLATE NOCASE, v.id",
+	SortChannel: "c.name COLLATE NOCASE, julianday(v.published), v.id",
+}
+
+// VideoSorts returns the accepted values for VideoQuery.Sort
+func VideoSorts() []string {
+	sorts := make([]string, 0, len(videoSorts))
+	for s := range videoSorts {
+		sorts = append(sorts, s)
+	}
+	slices.Sort(sorts)
+	return sorts
+}
+
+// VideoQuery filters the videos returned by QueryVideos. Zero values don't
+// filter anything.
+type VideoQuery struct {
+	// Channel matches the name or the ID of the channel
+	Channel string
+	// Since and Until limit the publication date, both inclusive
+	Since time.Time
+	Until time.Time
+	// FoundAfter and FoundUntil limit when the videos were first found by an
+	// update, the former exclusive and the latter inclusive
+	FoundAfter time.Time
+	FoundUntil time.Time
+	// Unwatched and Downloaded only return the videos in that state
+	Unwatched  bool
+	Downloaded bool
+	// Sort is one of the Sort constants. Defaults to SortOldest.
+	Sort string
+	// Limit caps the number of videos returned, after sorting
+	Limit int
+}
+
+// QueryVideos returns the videos matching the query
+func (y *Yrs) QueryVideos(q VideoQuery) ([]Video, error) {
+	if q.Sort == "" {
+		q.Sort = SortOldest
+	}
+	order, ok := videoSorts[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf(
+			"unknown sort %s, expected one of: %s",
+			q.Sort,
+			strings.Join(VideoSorts(), ", "),
+		)
+	}
+	if q.Limit < 0 {
+		return nil, errors.New("the limit can't be negative")
+	}
+
+	where := []string{"1=1"}
+	args := []any{}
+	if q.Channel != "" {
+		where = append(where, "(c.id=? OR c.name=? COLLATE NOCASE)")
+		args = append(args, q.Channel, q.Channel)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "julianday(v.published) >= julianday(?)")
+		args = append(args, q.Since.UTC().Format(time.DateTime))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "julianday(v.published) <= julianday(?)")
+		args = append(args, q.Until.UTC().Format(time.DateTime))
+	}
+	if !q.FoundAfter.IsZero() {
+		where = append(where, "julianday(v.found) > julianday(?)")
+		args = append(args, q.FoundAfter.UTC().Format(time.DateTime))
+	}
+	if !q.FoundUntil.IsZero() {
+		where = append(where, "julianday(v.found) <= julianday(?)")
+		args = append(args, q.FoundUntil.UTC().Format(time.DateTime))
+	}
+	if q.Unwatched {
+		where = append(where, "v.watched=0")
+	}
+	if q.Downloaded {
+		where = append(where, "v.downloaded=1")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM videos v
+		JOIN channels c ON (v.channel_id=c.id)
+		WHERE %s
+		ORDER BY %s
+	`, videoColumns, channelColumns, strings.Join(where, " AND "), order)
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := y.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't retrieve the videos: %w", err)
+	}
+	defer rows.Close()
+
+	videos := make([]Video, 0)
+	for rows.Next() {
+		v, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		videos = append(videos, v)
+	}
+
+	return videos, nil
+}
+
+// SetWatched marks the videos as watched, or as not watched
+func (y *Yrs) SetWatched(watched bool, videoIDs ...string) error {
+	tx, err := y.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin: %w", err)
+	}
+
+	for _, id := range videoIDs {
+		res, err := tx.Exec("UPDATE videos SET watched=? WHERE id=?", watched, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return fmt.Errorf("video %s not found", id)
+		}
+	}
+
+	return tx.Commit()
+}
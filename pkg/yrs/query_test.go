@@ -0,0 +1,87 @@
+package yrs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func setupQueryFixtures(t *testing.T, y *Yrs) {
+	t.Helper()
+
+	for n, name := range []string{"first", "second"} {
+		items := make([]*gofeed.Item, 0)
+		for i := range 3 {
+			items = append(items, &gofeed.Item{
+				Published: time.Now().AddDate(0, 0, -10*i).Add(time.Duration(n) * time.Hour).Format(time.RFC3339),
+				Title:     fmt.Sprintf("%s %d", name, i),
+				Link:      fmt.Sprintf("%s link %d", name, i),
+			})
+		}
+
+		err := y.subscribeChannel(Channel{
+			ID:   name + "-id",
+			Name: name,
+			RSS:  name + "-rss",
+		}, &gofeed.Feed{Items: items})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueryVideos(t *testing.T) {
+	y := mustCreateYrs(t)
+	setupQueryFixtures(t, y)
+
+	videos, err := y.QueryVideos(VideoQuery{Channel: "first", Sort: SortNewest, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Fatalf("Unexpected number of videos. Got %d, Expected %d", len(videos), 1)
+	}
+	if err := y.SetWatched(true, videos[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.db.Exec("UPDATE videos SET downloaded=1 WHERE title='second 2'"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		query VideoQuery
+		exp   []string
+	}{
+		{query: VideoQuery{}, exp: []string{"first 2", "second 2", "first 1", "second 1", "first 0", "second 0"}},
+		{query: VideoQuery{Channel: "second-id", Sort: SortNewest}, exp: []string{"second 0", "second 1", "second 2"}},
+		{query: VideoQuery{Channel: "FIRST", Unwatched: true}, exp: []string{"first 2", "first 1"}},
+		{query: VideoQuery{Since: time.Now().AddDate(0, 0, -15), Sort: SortTitle}, exp: []string{"first 0", "first 1", "second 0", "second 1"}},
+		{query: VideoQuery{Until: time.Now().AddDate(0, 0, -15), Sort: SortChannel}, exp: []string{"first 2", "second 2"}},
+		{query: VideoQuery{Downloaded: true}, exp: []string{"second 2"}},
+		{query: VideoQuery{Channel: "third"}, exp: []string{}},
+	}
+
+	for _, test := range testCases {
+		videos, err := y.QueryVideos(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		titles := make([]string, 0)
+		for _, v := range videos {
+			titles = append(titles, v.Title)
+		}
+		if fmt.Sprint(titles) != fmt.Sprint(test.exp) {
+			t.Errorf("Unexpected videos for %+v. Got %v, Expected %v", test.query, titles, test.exp)
+		}
+	}
+
+	if _, err := y.QueryVideos(VideoQuery{Sort: "random"}); err == nil {
+		t.Error("Expected an error for an unknown sort")
+	}
+	if err := y.SetWatched(true, "missing"); err == nil {
+		t.Error("Expected an error for an unknown video")
+	}
+}
@@ -0,0 +1,180 @@
+package yrs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var channelIDRe = regexp.MustCompile(`^UC[A-Za-z0-9_-]{22}$`)
+
+// Resolver finds out the ID of a YouTube channel out of the many ways there
+// are to refer to it: handles, custom URLs, legacy usernames, videos...
+type Resolver struct {
+	Client *http.Client
+}
+
+// ResolveChannelID returns the channel ID for any of:
+//   - a bare channel ID (UC...)
+//   - a bare handle (@name)
+//   - a /channel/, /@handle, /c/ or /user/ URL
+//   - a video URL, including shorts, live streams and youtu.be links
+func (r *Resolver) ResolveChannelID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if channelIDRe.MatchString(s) {
+		return s, nil
+	}
+
+	if strings.HasPrefix(s, "@") {
+		s = "https://www.youtube.com/" + s
+	} else {
+		s = withScheme(s)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid YouTube URL %s: %w", s, err)
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	if host != "youtube.com" && host != "youtu.be" {
+		return "", fmt.Errorf("%s is not a YouTube URL", s)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if host == "youtube.com" && len(parts) > 1 && parts[0] == "channel" &&
+		channelIDRe.MatchString(parts[1]) {
+		return parts[1], nil
+	}
+
+	return r.scrape(u.String())
+}
+
+func (r *Resolver) scrape(pageURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	// Skip the cookie consent page served to European visitors
+	req.AddCookie(&http.Cookie{Name: "SOCS", Value: "CAI"})
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error retrieving %s: %s", pageURL, res.Status)
+	}
+
+	channelID := findChannelID(res.Body)
+	if channelID == "" {
+		return "", fmt.Errorf("channelID not found in %s", pageURL)
+	}
+
+	return channelID, nil
+}
+
+// findChannelID looks for the channel ID in the metadata of a YouTube page.
+// Channel pages carry it in a meta tag, but the canonical link and the RSS
+// alternate link are used as fallbacks. Video pages also have an identifier
+// meta tag, but with the ID of the video, so candidates are validated.
+func findChannelID(r io.Reader) string {
+	var meta, canonical, alternate string
+	h := html.NewTokenizer(r)
+
+LOOP:
+	for {
+		tt := h.Next()
+		switch tt {
+		case html.ErrorToken:
+			break LOOP
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := h.Token()
+			if t.DataAtom != atom.Meta && t.DataAtom != atom.Link {
+				continue
+			}
+
+			attrMap := map[string]string{}
+			for _, a := range t.Attr {
+				attrMap[a.Key] = a.Val
+			}
+
+			switch {
+			case t.DataAtom == atom.Meta && meta == "" &&
+				(attrMap["itemprop"] == "identifier" ||
+					attrMap["itemprop"] == "channelId"):
+				if channelIDRe.MatchString(attrMap["content"]) {
+					meta = attrMap["content"]
+					break LOOP
+				}
+			case t.DataAtom == atom.Link && attrMap["rel"] == "canonical":
+				canonical = channelIDFromURL(attrMap["href"], "")
+			case t.DataAtom == atom.Link && attrMap["rel"] == "alternate" &&
+				attrMap["type"] == "application/rss+xml":
+				alternate = channelIDFromURL(attrMap["href"], "channel_id")
+			}
+		}
+	}
+
+	for _, id := range []string{meta, canonical, alternate} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// withScheme turns URLs written without scheme, like youtube.com/@name, into
+// https ones
+func withScheme(s string) string {
+	if !strings.Contains(s, "://") {
+		return "https://" + s
+	}
+	return s
+}
+
+// channelIDFromURL extracts the channel ID from the given query parameter or,
+// if param is empty, from a /channel/ path
+func channelIDFromURL(rawURL, param string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	var id string
+	if param != "" {
+		id = u.Query().Get(param)
+	} else if rest, ok := strings.CutPrefix(u.Path, "/channel/"); ok {
+		id, _, _ = strings.Cut(strings.Trim(rest, "/"), "/")
+	}
+
+	if !channelIDRe.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// SubscribeYouTube subscribes to the channel referred to by s, as long as
+// the Resolver can make sense of it
+func (y *Yrs) SubscribeYouTube(s string) error {
+	r := Resolver{Client: y.client}
+	channelID, err := r.ResolveChannelID(s)
+	if err != nil {
+		return err
+	}
+	return y.SubscribeYouTubeID(channelID)
+}
@@ -0,0 +1,86 @@
+package yrs
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureTransport serves the pages saved in testdata instead of hitting
+// YouTube
+type fixtureTransport map[string]string
+
+func (f fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, ok := f[req.URL.Path]
+	if !ok {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	body, err := os.Open(filepath.Join("testdata", "youtube", name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       body,
+		Request:    req,
+	}, nil
+}
+
+func TestResolveChannelID(t *testing.T) {
+	r := Resolver{Client: &http.Client{Transport: fixtureTransport{
+		"/@thisoldtony":  "handle.html",
+		"/user/featony":  "handle.html",
+		"/c/wintergatan": "canonical.html",
+		"/@mechanics":    "alternate.html",
+		"/watch":         "video.html",
+		"/shorts/abc":    "video.html",
+		"/JN-Pkbeu52E":   "video.html",
+		"/@nobody":       "notfound.html",
+	}}}
+
+	testCases := []struct {
+		in  string
+		exp string
+	}{
+		{in: "UC5NO8MgTQKHAWXp6z8Xl7yQ", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "https://www.youtube.com/channel/UC5NO8MgTQKHAWXp6z8Xl7yQ", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "@thisoldtony", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "https://www.youtube.com/@thisoldtony", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "https://www.youtube.com/user/featony", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "youtube.com/c/wintergatan", exp: "UCcXhhVwCT6_WqjkEniejRJQ"},
+		{in: "https://m.youtube.com/@mechanics", exp: "UCMrMVIBtqFW6O0-MWq26gqw"},
+		{in: "https://www.youtube.com/watch?v=JN-Pkbeu52E", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "https://www.youtube.com/shorts/abc", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+		{in: "https://youtu.be/JN-Pkbeu52E", exp: "UC5NO8MgTQKHAWXp6z8Xl7yQ"},
+	}
+
+	for _, test := range testCases {
+		got, err := r.ResolveChannelID(test.in)
+		if err != nil {
+			t.Errorf("Unexpected error resolving %s: %s", test.in, err)
+			continue
+		}
+		if got != test.exp {
+			t.Errorf("Unexpected channel ID for %s. Got: %s Expected: %s", test.in, got, test.exp)
+		}
+	}
+
+	for _, in := range []string{
+		"@nobody",
+		"https://www.youtube.com/@missing",
+		"https://example.org/@thisoldtony",
+	} {
+		if got, err := r.ResolveChannelID(in); err == nil {
+			t.Errorf("Expected error resolving %s, got %s", in, got)
+		}
+	}
+}
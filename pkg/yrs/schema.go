@@ -17,11 +17,26 @@ import (
 //go:embed db/migrations/*.sql
 var migrationsFS embed.FS
 
+// sqlitePragmas are set through the DSN, as the connection pool of database/sql
+// would only apply a PRAGMA statement to one of its connections. WAL lets
+// readers go on while someone writes, and the busy timeout makes writers wait
+// for each other, even across processes, instead of failing right away.
+// Transactions take the write lock when they start, since upgrading a read
+// lock later can fail without waiting.
+var sqlitePragmas = map[string]string{
+	"_foreign_keys": "on",
+	"_journal_mode": "WAL",
+	"_busy_timeout": "5000",
+	"_txlock":       "immediate",
+}
+
 type nullWriter struct{}
 
 func (*nullWriter) Write(_ []byte) (int, error) { return 0, nil }
 
-func ManageSchema(driver, dsn string) (*sql.DB, error) {
+// schemaManager returns the dbmate instance managing the schema of the
+// database
+func schemaManager(driver, dsn string) (*dbmate.DB, error) {
 	u, err := url.Parse(fmt.Sprintf("%s:%s", driver, strings.TrimPrefix(dsn, "file:")))
 	if err != nil {
 		return nil, fmt.Errorf("error managing database schema: %v", err)
@@ -31,11 +46,99 @@ func ManageSchema(driver, dsn string) (*sql.DB, error) {
 	dbm.FS = migrationsFS
 	dbm.AutoDumpSchema = false
 	dbm.Log = &nullWriter{}
+	return dbm, nil
+}
+
+func ManageSchema(driver, dsn string) (*sql.DB, error) {
+	dbm, err := schemaManager(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
 
 	err = dbm.CreateAndMigrate()
 	if err != nil {
 		return nil, err
 	}
 
+	return openDB(driver, dsn)
+}
+
+func openDB(driver, dsn string) (*sql.DB, error) {
+	if driver == "sqlite3" {
+		dsn = withPragmas(dsn)
+	}
 	return sql.Open(driver, dsn)
 }
+
+// PendingMigrations returns the migrations not applied to the database yet,
+// without applying them. New applies them when opening the database.
+func PendingMigrations(driver, dsn string) ([]string, error) {
+	dbm, err := schemaManager(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := dbm.FindMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the status of the migrations: %w", err)
+	}
+	pending := make([]string, 0)
+	for _, m := range migrations {
+		if !m.Applied {
+			pending = append(pending, m.FileName)
+		}
+	}
+	return pending, nil
+}
+
+// FTS5Available tells whether SQLite was built with FTS5, which the search
+// index needs. That takes building yrs with the fts5 tag.
+func FTS5Available() (bool, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var used bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); err != nil {
+		return false, fmt.Errorf("couldn't get the options SQLite was built with: %w", err)
+	}
+	return used, nil
+}
+
+// withPragmas adds the sqlitePragmas not already in dsn
+func withPragmas(dsn string) string {
+	base, query, _ := strings.Cut(dsn, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return dsn
+	}
+	for k, v := range sqlitePragmas {
+		if !values.Has(k) {
+			values.Set(k, v)
+		}
+	}
+	return base + "?" + values.Encode()
+}
+
+// Ping checks that the database can still be reached
+func (y *Yrs) Ping() error {
+	if err := y.db.Ping(); err != nil {
+		return fmt.Errorf("couldn't reach the database: %w", err)
+	}
+	return nil
+}
+
+// DatabaseSize returns the size of the database in bytes, not counting the
+// changes still in the write-ahead log
+func (y *Yrs) DatabaseSize() (int64, error) {
+	var pages, pageSize int64
+	if err := y.db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, fmt.Errorf("couldn't get the size of the database: %w", err)
+	}
+	if err := y.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("couldn't get the size of the database: %w", err)
+	}
+	return pages * pageSize, nil
+}
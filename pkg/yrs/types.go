@@ -4,26 +4,66 @@ import (
 	"time"
 )
 
+const (
+	// KindChannel sources publish their own uploads
+	KindChannel = "channel"
+	// KindPlaylist sources are playlists, usually owned by some channel
+	KindPlaylist = "playlist"
+)
+
 type Channel struct {
-	ID           string
-	URL          string
-	Name         string
-	RSS          string
-	Autodownload bool
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	Name         string `json:"name"`
+	RSS          string `json:"rss"`
+	Autodownload bool   `json:"autodownload"`
+	Provider     string `json:"provider"`
+	Kind         string `json:"kind"`
+	// IncludeShorts and IncludeLive choose whether YouTube Shorts and live
+	// streams are recorded along with the regular uploads
+	IncludeShorts bool `json:"include_shorts"`
+	IncludeLive   bool `json:"include_live"`
+	// Failures counts the updates in a row that couldn't fetch the feed, the
+	// last one failing with LastError
+	Failures    int        `json:"failures"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// Disabled channels are skipped by the updates
+	Disabled bool `json:"disabled"`
 }
 
 type Video struct {
-	ID         string
-	URL        string
-	Title      string
-	Published  time.Time
-	ChannelId  string
-	Downloaded bool
-	Channel    *Channel
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	Published  time.Time `json:"published"`
+	ChannelId  string    `json:"channel_id"`
+	Downloaded bool      `json:"downloaded"`
+	Thumbnail  string    `json:"thumbnail"`
+	// Path is where the video was downloaded to, if it was
+	Path    string   `json:"path"`
+	Watched bool     `json:"watched"`
+	Channel *Channel `json:"channel,omitempty"`
+}
+
+// Location is the file the video was downloaded to or, if it wasn't, its URL
+func (v Video) Location() string {
+	if v.Downloaded && v.Path != "" {
+		return v.Path
+	}
+	return v.URL
+}
+
+// Playlist is a local list of videos, like "watch later"
+type Playlist struct {
+	Name         string `json:"name"`
+	Autodownload bool   `json:"autodownload"`
+	Videos       int    `json:"videos"`
 }
 
 type SearchResult struct {
-	ID      string
-	Title   string
-	Channel string
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Channel string `json:"channel"`
+	URL     string `json:"url"`
 }
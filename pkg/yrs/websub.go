@@ -0,0 +1,267 @@
+package yrs
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// DefaultHub is the WebSub hub YouTube publishes its feeds through
+const DefaultHub = "https://pubsubhubbub.appspot.com/subscribe"
+
+var (
+	// ErrUnknownSubscription is returned when the hub verifies or pushes to
+	// a subscription that wasn't requested
+	ErrUnknownSubscription = errors.New("unknown WebSub subscription")
+	// ErrInvalidSignature is returned for pushed content not signed with the
+	// secret of the subscription
+	ErrInvalidSignature = errors.New("invalid WebSub signature")
+)
+
+// WebSub subscribes the YouTube channels to a hub, which pushes their new
+// videos as soon as they're published instead of waiting for the next update
+type WebSub struct {
+	// Hub is the URL subscriptions are requested to, DefaultHub when empty
+	Hub string
+	// Callback is the public URL the hub pushes to, followed by the ID of the
+	// channel, like https://example.org/yrs/websub
+	Callback string
+	// Lease asks for subscriptions lasting that long, leaving it to the hub
+	// when 0
+	Lease time.Duration
+}
+
+func (w WebSub) hub() string {
+	if w.Hub == "" {
+		return DefaultHub
+	}
+	return w.Hub
+}
+
+func (w WebSub) callback(channelID string) string {
+	return strings.TrimSuffix(w.Callback, "/") + "/" + url.PathEscape(channelID)
+}
+
+// websubTopic is the feed of the channel as known by the hub. The YouTube hub
+// expects the feeds under /xml.
+func websubTopic(c *Channel) string {
+	u, err := url.Parse(c.RSS)
+	if err != nil || !strings.HasPrefix(u.Path, "/feeds/") {
+		return c.RSS
+	}
+	u.Path = "/xml" + u.Path
+	return u.String()
+}
+
+// SubscribeWebSub asks the hub to push the videos of the channel. The
+// subscription is only active once the hub verifies it with VerifyWebSub.
+func (y *Yrs) SubscribeWebSub(w WebSub, channelID string) error {
+	c, err := y.GetChannel(channelID)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	// The secret is kept across renewals, so that the content pushed before
+	// the hub verifies them still checks out
+	topic := websubTopic(c)
+	_, err = y.db.Exec(`
+		INSERT INTO websub (channel_id, hub, topic, secret, requested)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id) DO UPDATE SET
+			hub=excluded.hub,
+			topic=excluded.topic,
+			requested=excluded.requested
+	`, c.ID, w.hub(), topic, hex.EncodeToString(b), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error recording the WebSub subscription of %s: %w", c.ID, err)
+	}
+
+	var secret string
+	if err := y.db.QueryRow("SELECT secret FROM websub WHERE channel_id=?", c.ID).Scan(&secret); err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic},
+		"hub.callback": {w.callback(c.ID)},
+		"hub.secret":   {secret},
+	}
+	if w.Lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(w.Lease.Seconds())))
+	}
+
+	res, err := y.client.PostForm(w.hub(), form)
+	if err != nil {
+		return fmt.Errorf("error subscribing %s to %s: %w", c.ID, w.hub(), err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("error subscribing %s to %s: %s", c.ID, w.hub(), res.Status)
+	}
+	return nil
+}
+
+// RenewWebSub subscribes the YouTube channels that aren't yet, and renews the
+// subscriptions expiring within the given time. Requests the hub didn't verify
+// within that time are sent again. Disabled channels are left to expire.
+func (y *Yrs) RenewWebSub(w WebSub, within time.Duration) error {
+	now := time.Now().UTC()
+	rows, err := y.db.Query(`
+		SELECT c.id
+		FROM channels c
+		LEFT JOIN websub s ON (s.channel_id=c.id)
+		WHERE c.provider=? AND c.kind=? AND NOT c.disabled AND (
+			s.channel_id IS NULL
+			OR (s.expires IS NULL AND julianday(s.requested) < julianday(?))
+			OR julianday(s.expires) < julianday(?)
+		)
+		ORDER BY c.id
+	`, ProviderYouTube, KindChannel, now.Add(-within), now.Add(within))
+	if err != nil {
+		return fmt.Errorf("couldn't list the WebSub subscriptions to renew: %w", err)
+	}
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, id := range ids {
+		if err := y.SubscribeWebSub(w, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerifyWebSub checks the request of the hub to verify a subscription of the
+// channel, recording until when it lasts. Unsubscriptions are only confirmed
+// for channels that are gone, and denied subscriptions are forgotten.
+func (y *Yrs) VerifyWebSub(channelID, mode, topic string, lease time.Duration) error {
+	switch mode {
+	case "subscribe":
+		var expires any
+		if lease > 0 {
+			expires = time.Now().UTC().Add(lease)
+		}
+		res, err := y.db.Exec(
+			"UPDATE websub SET expires=? WHERE channel_id=? AND topic=?",
+			expires, channelID, topic,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrUnknownSubscription, topic)
+		}
+		return nil
+	case "unsubscribe":
+		var id string
+		err := y.db.QueryRow("SELECT channel_id FROM websub WHERE channel_id=?", channelID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("still subscribed to %s", topic)
+	case "denied":
+		_, err := y.db.Exec("DELETE FROM websub WHERE channel_id=? AND topic=?", channelID, topic)
+		return err
+	default:
+		return fmt.Errorf("unknown WebSub mode %q", mode)
+	}
+}
+
+// ReceiveWebSub records the videos in the feed pushed by the hub for the
+// channel, as signed in the X-Hub-Signature header, and returns the new ones.
+// They're published and notified just like the ones found by Update, and
+// likewise ignored for disabled channels.
+func (y *Yrs) ReceiveWebSub(channelID string, body []byte, signature string) ([]Video, error) {
+	var secret string
+	err := y.db.QueryRow("SELECT secret FROM websub WHERE channel_id=?", channelID).Scan(&secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSubscription, channelID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !validSignature(secret, body, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	c, err := y.GetChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Disabled {
+		return []Video{}, nil
+	}
+	feed, err := gofeed.NewParser().ParseString(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the content pushed for %s: %w", channelID, err)
+	}
+
+	found, err := y.recordVideos([]channelFeed{{channel: c, feed: feed, filter: y.contentFilter(c)}})
+	if err != nil {
+		return nil, err
+	}
+	return found, y.announce(found)
+}
+
+// validSignature checks a signature like sha1=<hex HMAC of body>, with any of
+// the algorithms allowed by WebSub
+func validSignature(secret string, body []byte, signature string) bool {
+	method, sig, _ := strings.Cut(signature, "=")
+	var h func() hash.Hash
+	switch method {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
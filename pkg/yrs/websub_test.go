@@ -0,0 +1,216 @@
+package yrs
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const pushedVideo = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+  <title>YouTube video feed</title>
+  <updated>2026-01-02T15:04:05+00:00</updated>
+  <entry>
+    <id>yt:video:pushed</id>
+    <yt:videoId>pushed</yt:videoId>
+    <yt:channelId>UCwebsub</yt:channelId>
+    <title>Pushed video</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=pushed"/>
+    <published>2026-01-02T15:00:00+00:00</published>
+    <updated>2026-01-02T15:04:05+00:00</updated>
+  </entry>
+</feed>`
+
+// standInHub verifies subscriptions synchronously, like the first versions of
+// the protocol did, and remembers them to push content later
+type standInHub struct {
+	mu            sync.Mutex
+	requests      int
+	callback      string
+	topic         string
+	secret        string
+	verifications []string
+}
+
+func (h *standInHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests++
+	h.callback = r.PostFormValue("hub.callback")
+	h.topic = r.PostFormValue("hub.topic")
+	h.secret = r.PostFormValue("hub.secret")
+
+	q := url.Values{
+		"hub.mode":          {r.PostFormValue("hub.mode")},
+		"hub.topic":         {h.topic},
+		"hub.challenge":     {"challenge"},
+		"hub.lease_seconds": {r.PostFormValue("hub.lease_seconds")},
+	}
+	res, err := http.Get(h.callback + "?" + q.Encode())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	h.verifications = append(h.verifications, fmt.Sprintf("%d %s", res.StatusCode, body))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *standInHub) push(t *testing.T, content string) *http.Response {
+	t.Helper()
+	mac := hmac.New(sha1.New, []byte(h.secret))
+	mac.Write([]byte(content))
+
+	req, err := http.NewRequest(http.MethodPost, h.callback, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+// serveWebSub stands in for the web interface, which can't be imported here
+func serveWebSub(y *Yrs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channel := strings.TrimPrefix(r.URL.Path, "/websub/")
+		if r.Method == http.MethodGet {
+			q := r.URL.Query()
+			lease, _ := time.ParseDuration(q.Get("hub.lease_seconds") + "s")
+			if err := y.VerifyWebSub(channel, q.Get("hub.mode"), q.Get("hub.topic"), lease); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, q.Get("hub.challenge"))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if _, err := y.ReceiveWebSub(channel, body, r.Header.Get("X-Hub-Signature")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func TestWebSub(t *testing.T) {
+	n := &recordingNotifier{}
+	y := mustCreateYrs(t)
+	WithNotifiers(n)(y)
+	err := y.subscribeChannel(Channel{
+		ID:            "UCwebsub",
+		Name:          "websub",
+		RSS:           "https://www.youtube.com/feeds/videos.xml?channel_id=UCwebsub",
+		Provider:      ProviderYouTube,
+		Kind:          KindChannel,
+		IncludeShorts: true,
+		IncludeLive:   true,
+	}, &gofeed.Feed{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only YouTube channels are subscribed
+	if err := y.subscribeChannel(Channel{ID: "other", RSS: "other rss"}, &gofeed.Feed{}); err != nil {
+		t.Fatal(err)
+	}
+
+	callback := httptest.NewServer(serveWebSub(y))
+	defer callback.Close()
+	hub := &standInHub{}
+	hubSrv := httptest.NewServer(hub)
+	defer hubSrv.Close()
+
+	w := WebSub{Hub: hubSrv.URL, Callback: callback.URL + "/websub/", Lease: 48 * time.Hour}
+	if err := y.RenewWebSub(w, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if hub.requests != 1 || hub.callback != callback.URL+"/websub/UCwebsub" ||
+		hub.topic != "https://www.youtube.com/xml/feeds/videos.xml?channel_id=UCwebsub" {
+		t.Fatalf("Unexpected subscriptions: %d to %s for %s", hub.requests, hub.topic, hub.callback)
+	}
+	if len(hub.verifications) != 1 || hub.verifications[0] != "200 challenge" {
+		t.Fatalf("Unexpected verifications %v", hub.verifications)
+	}
+
+	// Nothing to renew yet
+	if err := y.RenewWebSub(w, time.Hour); err != nil || hub.requests != 1 {
+		t.Errorf("Expected no renewals. Got %d requests, %v", hub.requests, err)
+	}
+	secret := hub.secret
+	if err := y.RenewWebSub(w, 72*time.Hour); err != nil || hub.requests != 2 {
+		t.Errorf("Expected a renewal. Got %d requests, %v", hub.requests, err)
+	}
+	if hub.secret != secret {
+		t.Error("Expected the secret to be kept across renewals")
+	}
+
+	if res := hub.push(t, pushedVideo); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("Unexpected answer to the push: %s", res.Status)
+	}
+	videos, err := y.GetVideosByChannel("UCwebsub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].Title != "Pushed video" || len(n.videos) != 1 ||
+		videos[0].Thumbnail != "https://i.ytimg.com/vi/pushed/hqdefault.jpg" {
+		t.Errorf("Expected the pushed video to be recorded and notified. Got %+v, %+v", videos, n.videos)
+	}
+
+	// Pushed again when updated, but it's no longer new
+	hub.push(t, pushedVideo)
+	if len(n.videos) != 1 {
+		t.Errorf("Expected a single notification. Got %d", len(n.videos))
+	}
+
+	if _, err := y.ReceiveWebSub("UCwebsub", []byte(pushedVideo), "sha1=00"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected an invalid signature. Got %v", err)
+	}
+	if _, err := y.ReceiveWebSub("other", []byte(pushedVideo), "sha1=00"); !errors.Is(err, ErrUnknownSubscription) {
+		t.Errorf("Expected an unknown subscription. Got %v", err)
+	}
+	if err := y.VerifyWebSub("UCwebsub", "subscribe", "other topic", time.Hour); !errors.Is(err, ErrUnknownSubscription) {
+		t.Errorf("Expected the verification of another topic to fail. Got %v", err)
+	}
+
+	// Disabled channels get nothing pushed, nor renewed
+	if err := y.SetEnabled("UCwebsub", false); err != nil {
+		t.Fatal(err)
+	}
+	if res := hub.push(t, strings.ReplaceAll(pushedVideo, "pushed", "disabled")); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("Unexpected answer to the push: %s", res.Status)
+	}
+	if videos, err := y.GetVideosByChannel("UCwebsub"); err != nil || len(videos) != 1 || len(n.videos) != 1 {
+		t.Errorf("Expected the push to be ignored. Got %+v, %v", videos, err)
+	}
+	if err := y.RenewWebSub(w, 72*time.Hour); err != nil || hub.requests != 2 {
+		t.Errorf("Expected no renewals. Got %d requests, %v", hub.requests, err)
+	}
+
+	// Subscriptions go away with their channels
+	if err := y.Unsubscribe("UCwebsub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := y.VerifyWebSub("UCwebsub", "unsubscribe", hub.topic, 0); err != nil {
+		t.Errorf("Expected the unsubscription to be confirmed. Got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package yrs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	playlistRssFormat = "https://www.youtube.com/feeds/videos.xml?playlist_id=%s"
+)
+
+var playlistIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{12,}$`)
+
+// ParseYouTubePlaylistID extracts the playlist ID out of a playlist URL, a
+// video URL played from a playlist or a bare playlist ID
+func ParseYouTubePlaylistID(s string) (string, error) {
+	if playlistIDRe.MatchString(s) {
+		return s, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid playlist %s: %w", s, err)
+	}
+
+	id := u.Query().Get("list")
+	if !playlistIDRe.MatchString(id) {
+		return "", fmt.Errorf("playlist ID not found in %s", s)
+	}
+
+	return id, nil
+}
+
+// SubscribeYouTubePlaylist subscribes to the feed of the given playlist, which
+// can be given either as an URL or as a bare ID
+func (y *Yrs) SubscribeYouTubePlaylist(playlist string) error {
+	id, err := ParseYouTubePlaylistID(playlist)
+	if err != nil {
+		return err
+	}
+	return y.Subscribe(fmt.Sprintf(playlistRssFormat, id))
+}
+
+// YouTube keeps a few automatic playlists for every channel, named after the
+// channel ID with its "UC" prefix replaced
+const (
+	shortsPlaylistPrefix = "UUSH"
+	livePlaylistPrefix   = "UULV"
+)
+
+// contentFilter tells Shorts and live streams apart from regular uploads, so
+// they can be skipped for channels that don't want them
+type contentFilter struct {
+	skipShorts bool
+	skipLive   bool
+	shorts     map[string]bool
+	live       map[string]bool
+}
+
+// excludes reports whether the item has to be left out. Shorts are checked
+// against their URL and title, and against the IDs found in the channel's
+// Shorts playlist. Feeds link live streams like any other video, so they can
+// only be told apart by the channel's live playlist.
+func (f *contentFilter) excludes(item *gofeed.Item) bool {
+	if f == nil {
+		return false
+	}
+
+	id := youTubeVideoID(item)
+	if f.skipShorts && (isShort(item) || f.shorts[id]) {
+		return true
+	}
+	if f.skipLive && f.live[id] {
+		return true
+	}
+	return false
+}
+
+func isShort(item *gofeed.Item) bool {
+	return strings.Contains(item.Link, "/shorts/") ||
+		strings.Contains(strings.ToLower(item.Title), "#shorts")
+}
+
+// youTubeVideoID returns the ID YouTube uses for the video in the item, which
+// unlike its link is the same in every feed it shows up
+func youTubeVideoID(item *gofeed.Item) string {
+	if ids := item.Extensions["yt"]["videoId"]; len(ids) > 0 {
+		return ids[0].Value
+	}
+	return item.Link
+}
+
+// contentFilter builds the filter for the channel, or nil if the channel
+// wants everything. The playlists that can't be fetched are left out, rather
+// than failing the channel, falling back to the URL and title of the items.
+func (y *Yrs) contentFilter(c *Channel) *contentFilter {
+	if c.IncludeShorts && c.IncludeLive {
+		return nil
+	}
+
+	f := &contentFilter{skipShorts: !c.IncludeShorts, skipLive: !c.IncludeLive}
+
+	// Only YouTube channels have the automatic playlists
+	u, err := url.Parse(c.RSS)
+	if err != nil || c.Provider != ProviderYouTube {
+		return f
+	}
+	channelID, ok := strings.CutPrefix(u.Query().Get("channel_id"), "UC")
+	if !ok {
+		return f
+	}
+
+	if f.skipShorts {
+		if f.shorts, err = y.playlistVideoIDs(shortsPlaylistPrefix + channelID); err != nil {
+			slog.Warn("couldn't tell the Shorts apart", "channel", c.ID, "err", err)
+		}
+	}
+	if f.skipLive {
+		if f.live, err = y.playlistVideoIDs(livePlaylistPrefix + channelID); err != nil {
+			slog.Warn("couldn't tell the live streams apart", "channel", c.ID, "err", err)
+		}
+	}
+
+	return f
+}
+
+// playlistVideoIDs returns the IDs of the videos in the playlist. Channels
+// without Shorts or live streams don't have those playlists at all, so a 404
+// just means there's nothing to exclude.
+func (y *Yrs) playlistVideoIDs(playlistID string) (map[string]bool, error) {
+	ids := map[string]bool{}
+	feed, err := y.fetchFeed(fmt.Sprintf(playlistRssFormat, playlistID))
+	var httpErr gofeed.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch the playlist %s: %w", playlistID, err)
+	}
+
+	for _, item := range feed.Items {
+		ids[youTubeVideoID(item)] = true
+	}
+	return ids, nil
+}
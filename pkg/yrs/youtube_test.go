@@ -0,0 +1,150 @@
+package yrs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/samber/lo"
+)
+
+func TestParseYouTubePlaylistID(t *testing.T) {
+	testCases := []struct {
+		in  string
+		exp string
+		err bool
+	}{
+		{in: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", exp: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG"},
+		{
+			in:  "https://www.youtube.com/playlist?list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG",
+			exp: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG",
+		},
+		{
+			in:  "https://www.youtube.com/watch?v=JN-Pkbeu52E&list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG",
+			exp: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG",
+		},
+		{in: "https://www.youtube.com/watch?v=JN-Pkbeu52E", err: true},
+	}
+
+	for _, test := range testCases {
+		got, err := ParseYouTubePlaylistID(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("Expected error for %s, got %s", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.in, err)
+		}
+		if got != test.exp {
+			t.Errorf("Unexpected playlist ID. Got: %s Expected: %s", got, test.exp)
+		}
+	}
+}
+
+func TestContentFilter(t *testing.T) {
+	y := mustCreateYrs(t)
+
+	ytItem := func(id, link, title string) *gofeed.Item {
+		return &gofeed.Item{
+			Published: "2006-01-02T15:04:05Z",
+			Title:     title,
+			Link:      link,
+			Extensions: ext.Extensions{"yt": map[string][]ext.Extension{
+				"videoId": {{Value: id}},
+			}},
+		}
+	}
+
+	feed := &gofeed.Feed{
+		Items: []*gofeed.Item{
+			ytItem("regular", "https://www.youtube.com/watch?v=regular", "regular"),
+			ytItem("short1", "https://www.youtube.com/shorts/short1", "short by URL"),
+			ytItem("short2", "https://www.youtube.com/watch?v=short2", "short by tag #Shorts"),
+			ytItem("short3", "https://www.youtube.com/watch?v=short3", "short by playlist"),
+			ytItem("live1", "https://www.youtube.com/watch?v=live1", "live by playlist"),
+		},
+	}
+
+	c := Channel{ID: "id", Name: "name", RSS: "rss", IncludeLive: true}
+	filter := &contentFilter{
+		skipShorts: true,
+		shorts:     map[string]bool{"short3": true},
+		live:       map[string]bool{"live1": true},
+	}
+	if err := y.subscribeChannel(c, &gofeed.Feed{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := y.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updateChannelVideos(tx, &c, nil, feed, filter); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	videos, err := y.GetVideos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := lo.Map(videos, func(v Video, _ int) string { return v.Title })
+	if len(titles) != 2 || !lo.Contains(titles, "regular") || !lo.Contains(titles, "live by playlist") {
+		t.Errorf("Unexpected videos after filtering out shorts. Got: %v", titles)
+	}
+}
+
+// redirectTransport sends every request to the given server instead
+type redirectTransport struct {
+	srv *httptest.Server
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", strings.TrimPrefix(t.srv.URL, "http://")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPlaylistVideoIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("playlist_id") {
+		case shortsPlaylistPrefix + "found":
+			fmt.Fprint(w, `<rss version="2.0"><channel><item><link>short</link></item></channel></rss>`)
+		case livePlaylistPrefix + "found":
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	y := mustCreateYrs(t)
+	WithHTTPClient(&http.Client{Transport: redirectTransport{srv}})(y)
+
+	c := &Channel{RSS: fmt.Sprintf(rssFormat, "UCfound"), Provider: ProviderYouTube}
+	filter := y.contentFilter(c)
+	if len(filter.shorts) != 1 || !filter.shorts["short"] || len(filter.live) != 0 {
+		t.Errorf("Unexpected filter. Got %+v", filter)
+	}
+
+	// The playlists that can't be fetched don't fail the channel, leaving
+	// only the URL and title of the items to tell the Shorts apart
+	c.RSS = fmt.Sprintf(rssFormat, "UCbroken")
+	filter = y.contentFilter(c)
+	if filter == nil || filter.shorts != nil || filter.live != nil {
+		t.Errorf("Expected a filter without the playlists. Got %+v", filter)
+	}
+	short := &gofeed.Item{Link: "https://www.youtube.com/shorts/short1"}
+	if !filter.excludes(short) {
+		t.Error("Expected the Short to be excluded by its URL")
+	}
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pingInterval keeps the event streams alive through proxies closing idle
+// connections
+const pingInterval = 30 * time.Second
+
+// streamedEvents are the events sent to the browsers
+var streamedEvents = []yrs.EventType{
+	yrs.EventUpdateProgress,
+	yrs.EventUpdateFinished,
+	yrs.EventUpdateFailed,
+	yrs.EventVideoFound,
+	yrs.EventDownloadStarted,
+	yrs.EventDownloadProgress,
+	yrs.EventDownloadFinished,
+	yrs.EventDownloadFailed,
+}
+
+// broker passes on the events of yrs to the browsers listening to them.
+// Browsers too slow to keep up miss some events, instead of holding up yrs.
+type broker struct {
+	mu      sync.Mutex
+	clients map[chan yrs.Event]struct{}
+	done    chan struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		clients: make(map[chan yrs.Event]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (b *broker) Handle(e yrs.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *broker) subscribe() chan yrs.Event {
+	ch := make(chan yrs.Event, 64)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clients[ch] = struct{}{}
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan yrs.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients, ch)
+}
+
+// close ends every stream, which would otherwise hold up the shutdown of the
+// server
+func (b *broker) close() {
+	close(b.done)
+}
+
+// streamEvents sends the events as Server-Sent Events, named after their type
+// and with the event as JSON
+func (b *broker) streamEvents(c *gin.Context) {
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e := <-ch:
+			c.SSEvent(string(e.Type), e)
+		case <-ping.C:
+			io.WriteString(w, ": ping\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		case <-b.done:
+			return false
+		}
+		return true
+	})
+}
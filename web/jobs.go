@@ -0,0 +1,164 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keptJobs is how many finished jobs can still be looked up
+const keptJobs = 20
+
+// The states of a job
+const (
+	jobRunning  = "running"
+	jobFinished = "finished"
+	jobFailed   = "failed"
+)
+
+// job is an update started from the web interface, running in the background
+type job struct {
+	ID        string       `json:"id"`
+	State     string       `json:"state"`
+	Started   time.Time    `json:"started"`
+	Finished  *time.Time   `json:"finished,omitempty"`
+	Progress  yrs.Progress `json:"progress"`
+	NewVideos int          `json:"new_videos"`
+	Error     string       `json:"error,omitempty"`
+}
+
+func (j *job) Running() bool {
+	return j.State == jobRunning
+}
+
+// jobs runs the updates started from the web interface, one at a time, and
+// keeps track of how they're going
+type jobs struct {
+	y       *yrs.Yrs
+	running sync.WaitGroup
+
+	mu      sync.Mutex
+	current *job
+	byID    map[string]*job
+	order   []string
+}
+
+func newJobs(y *yrs.Yrs) *jobs {
+	j := &jobs{y: y, byID: make(map[string]*job)}
+	y.Events().Subscribe(j, yrs.EventUpdateProgress)
+	return j
+}
+
+// Handle follows the progress of the running job. Only one update runs at a
+// time, so the progress is always the one of the job while it runs.
+func (j *jobs) Handle(e yrs.Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.current != nil && e.Progress != nil {
+		j.current.Progress = *e.Progress
+	}
+	return nil
+}
+
+// start starts an update, unless one is running already. Either way, it
+// returns the job of the update, and whether it was started.
+func (j *jobs) start() (job, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.current != nil {
+		return *j.current, false, nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return job{}, false, err
+	}
+	current := &job{ID: hex.EncodeToString(b), State: jobRunning, Started: time.Now()}
+	j.current = current
+	j.byID[current.ID] = current
+	j.order = append(j.order, current.ID)
+	if len(j.order) > keptJobs {
+		delete(j.byID, j.order[0])
+		j.order = j.order[1:]
+	}
+
+	j.running.Add(1)
+	go j.run(current)
+	return *current, true, nil
+}
+
+func (j *jobs) run(current *job) {
+	defer j.running.Done()
+	videos, err := j.y.Update()
+	if errors.Is(err, yrs.ErrNotification) {
+		slog.Error("notification failed", "err", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	current.Finished = &now
+	current.NewVideos = len(videos)
+	current.State = jobFinished
+	// Failing channels are told about, but the update still finishes
+	switch {
+	case errors.Is(err, yrs.ErrChannelsFailed):
+		current.Error = err.Error()
+	case err != nil && !errors.Is(err, yrs.ErrNotification):
+		current.State = jobFailed
+		current.Error = err.Error()
+	}
+	j.current = nil
+}
+
+// wait waits for the running job to finish
+func (j *jobs) wait() {
+	j.running.Wait()
+}
+
+// get returns a copy of the job with the given ID
+func (j *jobs) get(id string) (job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	found, ok := j.byID[id]
+	if !ok {
+		return job{}, false
+	}
+	return *found, true
+}
+
+// startUpdate starts an update in the background, answering with its job.
+// While one is running, no other is started, and the running one is returned
+// with a 409.
+func (w *WebYrs) startUpdate(c *gin.Context) {
+	started, ok, err := w.updates.start()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", w.buildUrl("/update/"+started.ID))
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "update already in progress", "job": started})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"job": started})
+}
+
+// updateStatus answers with the job of an update
+func (w *WebYrs) updateStatus(c *gin.Context) {
+	found, ok := w.updates.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown job " + c.Param("id")})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": found})
+}
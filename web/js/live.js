@@ -0,0 +1,141 @@
+// Updates the list of videos as the events of yrs arrive, through the
+// Server-Sent Events of /events
+(function () {
+  const root = document.currentScript.dataset.root;
+  const status = document.getElementById("update-status");
+  const button = document.getElementById("update-button");
+  const downloads = document.getElementById("downloads");
+  const table = document.getElementById("videos");
+  const events = new EventSource(root + "/events");
+
+  function cell(row, text, href) {
+    const td = row.insertCell();
+    if (href) {
+      const a = document.createElement("a");
+      a.href = href;
+      a.textContent = text;
+      td.appendChild(a);
+    } else {
+      td.textContent = text;
+    }
+    return td;
+  }
+
+  function percent(progress) {
+    if (!progress.total) {
+      return Math.round(progress.done / 1048576) + " MB";
+    }
+    return Math.round(100 * progress.done / progress.total) + "%";
+  }
+
+  function download(e) {
+    const id = "download-" + e.video.id;
+    let line = document.getElementById(id);
+    if (!line) {
+      line = document.createElement("div");
+      line.id = id;
+      downloads.appendChild(line);
+    }
+    return line;
+  }
+
+  function found(count) {
+    return count ? "Found " + count + " new videos" : "No new videos found";
+  }
+
+  function result(job) {
+    if (job.state === "failed") {
+      return "Update failed: " + job.error;
+    }
+    return found(job.new_videos) + (job.error ? ". " + job.error : "");
+  }
+
+  // Follows the update started from this page until it's done, as the events
+  // may be missed while loading it
+  function poll(id) {
+    fetch(root + "/update/" + encodeURIComponent(id))
+      .then((res) => res.json())
+      .then(({ job }) => {
+        if (!job) {
+          return;
+        }
+        if (job.state === "running") {
+          status.textContent = "Updating, " + job.progress.done + " of " + job.progress.total + " channels";
+          setTimeout(() => poll(id), 1000);
+          return;
+        }
+        button.disabled = false;
+        status.textContent = result(job);
+      });
+  }
+
+  if (status.dataset.job) {
+    setTimeout(() => poll(status.dataset.job), 1000);
+  }
+
+  events.addEventListener("update.progress", (msg) => {
+    const e = JSON.parse(msg.data);
+    button.disabled = true;
+    status.textContent = "Updating, " + e.progress.done + " of " + e.progress.total + " channels";
+    if (e.error) {
+      status.textContent += ". Couldn't update " + e.channel.name + ": " + e.error;
+    }
+  });
+
+  // Failing channels don't stop the rest, and the update finishes anyway
+  let failing = [];
+
+  events.addEventListener("update.finished", (msg) => {
+    const e = JSON.parse(msg.data);
+    button.disabled = false;
+    status.textContent = found(e.count);
+    if (failing.length) {
+      status.textContent += ". Couldn't update " + failing.join(", ");
+    }
+    failing = [];
+  });
+
+  events.addEventListener("update.failed", (msg) => {
+    const e = JSON.parse(msg.data);
+    if (e.channel) {
+      failing.push(e.channel.name);
+      return;
+    }
+    button.disabled = false;
+    status.textContent = "Update failed: " + e.error;
+  });
+
+  events.addEventListener("video.found", (msg) => {
+    const v = JSON.parse(msg.data).video;
+    const row = table.tBodies[0].insertRow(0);
+    row.className = "table-success";
+    row.appendChild(document.createElement("th")).scope = "row";
+    cell(row, v.id);
+    cell(row, v.published.slice(0, 10));
+    cell(row, v.title);
+    cell(row, v.channel.name, root + "/list-videos?channel=" + encodeURIComponent(v.channel.name));
+    cell(row, v.url, v.url);
+    cell(row, "");
+    table.hidden = false;
+  });
+
+  events.addEventListener("download.started", (msg) => {
+    const e = JSON.parse(msg.data);
+    download(e).textContent = "Downloading " + e.video.title;
+  });
+
+  events.addEventListener("download.progress", (msg) => {
+    const e = JSON.parse(msg.data);
+    download(e).textContent = "Downloading " + e.video.title + ": " + percent(e.progress);
+  });
+
+  events.addEventListener("download.finished", (msg) => {
+    const e = JSON.parse(msg.data);
+    download(e).textContent = "Downloaded " + e.video.title + " to " + e.video.path;
+  });
+
+  events.addEventListener("download.failed", (msg) => {
+    const e = JSON.parse(msg.data);
+    download(e).textContent = "Couldn't download " + e.video.title + ": " + e.error;
+  });
+})();
@@ -0,0 +1,523 @@
+// Package web serves the web interface of yrs
+package web
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miquelruiz/yrs/internal/metrics"
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/gin-contrib/multitemplate"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+const (
+	ENTRIES_IN_FEED = 40
+)
+
+//go:embed templates js css
+var assets embed.FS
+
+// WebYrs serves the web interface of a Yrs
+type WebYrs struct {
+	y *yrs.Yrs
+	// rootUrl is the path everything is served under
+	rootUrl string
+	// updates are the ones started from the web interface
+	updates *jobs
+}
+
+// Server is the web interface built by NewServer
+type Server struct {
+	*http.Server
+	updates *jobs
+}
+
+// Shutdown shuts down the server like http.Server.Shutdown, and then waits
+// for the update started from the web interface to finish, if any. Updates
+// can't be interrupted, so that doesn't stop when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Server.Shutdown(ctx)
+	s.updates.wait()
+	return err
+}
+
+func createRender() multitemplate.Renderer {
+	r := multitemplate.NewRenderer()
+	r.AddFromFS("index", assets, "templates/base.tmpl")
+	r.AddFromFS("listChannels", assets, "templates/base.tmpl", "templates/channels.tmpl")
+	r.AddFromFS("videos", assets, "templates/base.tmpl", "templates/videos.tmpl")
+	r.AddFromFS("playlists", assets, "templates/base.tmpl", "templates/playlists.tmpl")
+	r.AddFromFS("playlist", assets, "templates/base.tmpl", "templates/playlist.tmpl")
+	return r
+}
+
+// static serves one of the directories of assets
+func static(dir string) http.FileSystem {
+	sub, err := fs.Sub(assets, dir)
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(sub)
+}
+
+// observeRequests measures how long requests take to answer, by the route
+// they matched
+func observeRequests(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// logRequests logs every request once answered
+func logRequests(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	slog.Info(
+		"request",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"duration", time.Since(start),
+		"client", c.ClientIP(),
+	)
+}
+
+func (w *WebYrs) listChannels(c *gin.Context) {
+	var err error
+	y := w.y
+	errStr := c.Query("error")
+	if errStr != "" {
+		err = errors.New(errStr)
+	}
+	channels, errGet := y.GetChannels()
+	if err != nil || errGet != nil {
+		err = errors.Join(err, errGet)
+	}
+	failing := lo.Filter(channels, func(c yrs.Channel, _ int) bool {
+		return c.Failures > 0 || c.Disabled
+	})
+	c.HTML(http.StatusOK, "listChannels", gin.H{
+		"rootUrl":  w.rootUrl,
+		"channels": channels,
+		"failing":  failing,
+		"error":    err,
+	})
+}
+
+func (w *WebYrs) deleteChannel(c *gin.Context) {
+	ch := c.PostForm("channel")
+	log.Print("Deleting " + ch)
+	y := w.y
+	err := y.Unsubscribe(ch)
+	var msg string
+	if err == nil {
+		msg = fmt.Sprintf("?error=%s", url.QueryEscape("Deleted channel "+ch))
+	} else {
+		msg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+msg)
+}
+
+func (w *WebYrs) channelOptions(c *gin.Context) {
+	ch := c.PostForm("channel")
+	y := w.y
+	err := y.SetContentOptions(
+		ch,
+		c.PostForm("shorts") == "on",
+		c.PostForm("live") == "on",
+	)
+	var errArg string
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+errArg)
+}
+
+// channelEnabled enables or disables a channel
+func (w *WebYrs) channelEnabled(c *gin.Context) {
+	y := w.y
+	err := y.SetEnabled(c.PostForm("channel"), c.PostForm("enabled") == "on")
+	var errArg string
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+errArg)
+}
+
+// healthz answers as long as the server is running
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz answers whether requests can be served, which takes reaching the
+// database
+func (w *WebYrs) readyz(c *gin.Context) {
+	y := w.y
+	if err := y.Ping(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func (w *WebYrs) getVideos(vGetter func() ([]yrs.Video, error), n int) ([]yrs.Video, error) {
+	videos, err := vGetter()
+	if n != 0 && len(videos) > n {
+		videos = videos[len(videos)-n:]
+	}
+	return videos, err
+}
+
+func (w *WebYrs) listVideos(c *gin.Context) {
+	var parseErr error
+	lastInt := 0
+	last := c.DefaultQuery("last", "20")
+	if last != "all" {
+		lastInt, parseErr = strconv.Atoi(last)
+	}
+
+	// Updates run in the background, and the page follows the job
+	if c.Request.Method == "POST" {
+		q := c.Request.URL.Query()
+		started, _, err := w.updates.start()
+		if err != nil {
+			q.Set("error", err.Error())
+		} else {
+			q.Set("job", started.ID)
+		}
+		c.Redirect(303, w.buildUrl("/list-videos")+"?"+q.Encode())
+		return
+	}
+
+	var updateJob *job
+	if id := c.Query("job"); id != "" {
+		if j, ok := w.updates.get(id); ok {
+			updateJob = &j
+		}
+	}
+
+	var videos []yrs.Video
+	var getVErr error
+	y := w.y
+	channel := c.DefaultQuery("channel", "")
+	if channel != "" {
+		videos, getVErr = w.getVideos(
+			func() ([]yrs.Video, error) { return y.GetVideosByChannel(channel) },
+			lastInt,
+		)
+	} else {
+		videos, getVErr = w.getVideos(y.GetVideos, lastInt)
+	}
+
+	playlists, getPErr := y.GetPlaylists()
+	updating, lockErr := y.UpdateLock()
+
+	var queryErr error
+	if errStr := c.Query("error"); errStr != "" {
+		queryErr = errors.New(errStr)
+	}
+
+	c.HTML(http.StatusOK, "videos", gin.H{
+		"rootUrl":   w.rootUrl,
+		"videos":    videos,
+		"playlists": playlists,
+		"job":       updateJob,
+		"updating":  updating,
+		"error":     errors.Join(queryErr, getVErr, getPErr, parseErr, lockErr),
+	})
+}
+
+func (w *WebYrs) generateFeed(c *gin.Context) {
+	y := w.y
+	videos, err := w.getVideos(y.GetVideos, ENTRIES_IN_FEED)
+	if err != nil {
+		c.XML(500, yrs.NewAtomFeed("YouTube RSS Subscriber", "yrs", nil))
+		return
+	}
+
+	c.XML(200, yrs.NewAtomFeed("YouTube RSS Subscriber", "yrs", videos))
+}
+
+func (w *WebYrs) search(c *gin.Context) {
+	y := w.y
+	results, err := y.Search(c.Query("term"))
+
+	var videos []yrs.Video
+	if err == nil {
+		ids := lo.Map(results, func(r yrs.SearchResult, _ int) string {
+			return r.ID
+		})
+		videos, err = y.GetVideosByID(ids)
+	}
+
+	playlists, getPErr := y.GetPlaylists()
+
+	c.HTML(http.StatusOK, "videos", gin.H{
+		"show_update": false,
+		"rootUrl":     w.rootUrl,
+		"videos":      videos,
+		"playlists":   playlists,
+		"error":       errors.Join(err, getPErr),
+	})
+}
+
+func (w *WebYrs) listPlaylists(c *gin.Context) {
+	var err error
+	y := w.y
+	errStr := c.Query("error")
+	if errStr != "" {
+		err = errors.New(errStr)
+	}
+	playlists, errGet := y.GetPlaylists()
+	c.HTML(http.StatusOK, "playlists", gin.H{
+		"rootUrl":   w.rootUrl,
+		"playlists": playlists,
+		"error":     errors.Join(err, errGet),
+	})
+}
+
+func (w *WebYrs) createPlaylist(c *gin.Context) {
+	var errArg string
+	y := w.y
+	err := y.CreatePlaylist(c.PostForm("name"), c.PostForm("autodownload") == "on")
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/playlists")+errArg)
+}
+
+func (w *WebYrs) deletePlaylist(c *gin.Context) {
+	var errArg string
+	y := w.y
+	err := y.DeletePlaylist(c.PostForm("playlist"))
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/playlists")+errArg)
+}
+
+func (w *WebYrs) showPlaylist(c *gin.Context) {
+	var err error
+	y := w.y
+	errStr := c.Query("error")
+	if errStr != "" {
+		err = errors.New(errStr)
+	}
+	name := c.Param("name")
+	videos, errGet := y.GetPlaylistVideos(name)
+	c.HTML(http.StatusOK, "playlist", gin.H{
+		"rootUrl":  w.rootUrl,
+		"playlist": name,
+		"videos":   videos,
+		"error":    errors.Join(err, errGet),
+	})
+}
+
+// addToPlaylist redirects back to the page the video was added from
+func (w *WebYrs) addToPlaylist(c *gin.Context) {
+	y := w.y
+	err := y.AddToPlaylist(c.PostForm("playlist"), c.PostForm("video"))
+
+	back := w.localReferer(c.Request.Referer())
+	if err != nil {
+		q := back.Query()
+		q.Set("error", err.Error())
+		back.RawQuery = q.Encode()
+	}
+	c.Redirect(303, back.String())
+}
+
+// localReferer keeps the path and query of the referer, falling back to the
+// list of videos unless it's a page of the web interface
+func (w *WebYrs) localReferer(referer string) *url.URL {
+	fallback := &url.URL{Path: w.buildUrl("/list-videos")}
+	u, err := url.Parse(referer)
+	if err != nil || strings.HasPrefix(u.Path, "//") || !strings.HasPrefix(u.Path, w.buildUrl("/")) {
+		return fallback
+	}
+	return &url.URL{Path: u.Path, RawQuery: u.RawQuery}
+}
+
+func (w *WebYrs) removeFromPlaylist(c *gin.Context) {
+	var errArg string
+	y := w.y
+	name := c.Param("name")
+	err := y.RemoveFromPlaylist(name, c.PostForm("video"))
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/playlist/"+url.PathEscape(name))+errArg)
+}
+
+func (w *WebYrs) moveInPlaylist(c *gin.Context) {
+	var errArg string
+	y := w.y
+	name := c.Param("name")
+	position, err := strconv.Atoi(c.PostForm("position"))
+	if err == nil {
+		err = y.MoveInPlaylist(name, c.PostForm("video"), position)
+	}
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/playlist/"+url.PathEscape(name))+errArg)
+}
+
+func (w *WebYrs) playlistFeed(c *gin.Context) {
+	y := w.y
+	name := c.Param("name")
+	videos, err := y.GetPlaylistVideos(name)
+	if err != nil {
+		c.XML(404, yrs.NewAtomFeed(name, "yrs:playlist:"+name, nil))
+		return
+	}
+
+	c.XML(200, yrs.NewAtomFeed(name, "yrs:playlist:"+name, videos))
+}
+
+func (w *WebYrs) playlistM3U(c *gin.Context) {
+	y := w.y
+	videos, err := y.GetPlaylistVideos(c.Param("name"))
+	if err != nil {
+		c.String(404, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "audio/x-mpegurl")
+	c.Status(200)
+	if err := yrs.WriteM3U(c.Writer, videos); err != nil {
+		log.Println(err)
+	}
+}
+
+func (w *WebYrs) subscribeYouTube(c *gin.Context) {
+	var errArg string
+	y := w.y
+	err := y.SubscribeYouTube(c.PostForm("channel"))
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+errArg)
+}
+
+func (w *WebYrs) subscribePlaylist(c *gin.Context) {
+	var errArg string
+	y := w.y
+	err := y.SubscribeYouTubePlaylist(c.PostForm("playlist"))
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+errArg)
+}
+
+func (w *WebYrs) subscribe(c *gin.Context) {
+	var errArg string
+	y := w.y
+	err := y.Subscribe(c.PostForm("rss"))
+	if err != nil {
+		errArg = fmt.Sprintf("?error=%s", url.QueryEscape(err.Error()))
+	}
+	c.Redirect(303, w.buildUrl("/list-channels")+errArg)
+}
+
+func (w *WebYrs) index(c *gin.Context) {
+	c.HTML(http.StatusOK, "index", gin.H{"rootUrl": w.rootUrl})
+}
+
+func (w *WebYrs) buildUrl(url string) string {
+	return fmt.Sprintf("%s%s", w.rootUrl, url)
+}
+
+func cleanRootUrl(root string) string {
+	if root == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		"/%s",
+		strings.TrimSuffix(strings.TrimPrefix(root, "/"), "/"),
+	)
+}
+
+// NewServer builds the server of the web interface for y, listening on addr
+// and serving everything under root. With m, the latencies of the requests are
+// measured and served with the rest of the metrics under /metrics.
+func NewServer(y *yrs.Yrs, addr, root string, m *metrics.Metrics) *Server {
+	events := newBroker()
+	y.Events().Subscribe(events, streamedEvents...)
+	wy := &WebYrs{y: y, rootUrl: cleanRootUrl(root), updates: newJobs(y)}
+	buildUrl := wy.buildUrl
+
+	r := gin.New()
+	r.Use(logRequests, gin.Recovery())
+	if m != nil {
+		r.Use(observeRequests(m))
+		r.GET(buildUrl("/metrics"), gin.WrapH(m))
+	}
+	r.HTMLRender = createRender()
+
+	r.StaticFS(buildUrl("/js/"), static("js"))
+	r.StaticFS(buildUrl("/css/"), static("css"))
+
+	r.GET(buildUrl("/list-channels"), wy.listChannels)
+	r.POST(buildUrl("/delete-channel"), wy.deleteChannel)
+	r.POST(buildUrl("/channel-options"), wy.channelOptions)
+	r.POST(buildUrl("/channel-enabled"), wy.channelEnabled)
+
+	r.GET(buildUrl("/list-videos"), wy.listVideos)
+	r.POST(buildUrl("/list-videos"), wy.listVideos)
+
+	r.POST(buildUrl("/subscribeYouTube"), wy.subscribeYouTube)
+	r.POST(buildUrl("/subscribePlaylist"), wy.subscribePlaylist)
+	r.POST(buildUrl("/subscribe"), wy.subscribe)
+
+	r.GET(buildUrl("/playlists"), wy.listPlaylists)
+	r.POST(buildUrl("/playlists"), wy.createPlaylist)
+	r.POST(buildUrl("/delete-playlist"), wy.deletePlaylist)
+	r.POST(buildUrl("/add-to-playlist"), wy.addToPlaylist)
+	r.GET(buildUrl("/playlist/:name"), wy.showPlaylist)
+	r.POST(buildUrl("/playlist/:name/remove"), wy.removeFromPlaylist)
+	r.POST(buildUrl("/playlist/:name/move"), wy.moveInPlaylist)
+	r.GET(buildUrl("/playlist/:name/feed"), wy.playlistFeed)
+	r.GET(buildUrl("/playlist/:name/m3u"), wy.playlistM3U)
+
+	r.GET(buildUrl("/websub/:channel"), wy.verifyWebSub)
+	r.POST(buildUrl("/websub/:channel"), wy.receiveWebSub)
+
+	r.POST(buildUrl("/update"), wy.startUpdate)
+	r.GET(buildUrl("/update/:id"), wy.updateStatus)
+	r.GET(buildUrl("/events"), events.streamEvents)
+
+	r.GET(buildUrl("/feed"), wy.generateFeed)
+	r.GET(buildUrl("/search"), wy.search)
+
+	r.GET(buildUrl("/healthz"), healthz)
+	r.GET(buildUrl("/readyz"), wy.readyz)
+
+	r.GET(buildUrl("/"), wy.index)
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+	srv.RegisterOnShutdown(events.close)
+	return &Server{Server: srv, updates: wy.updates}
+}
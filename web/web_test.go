@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blockingFeed serves an empty feed, holding up the requests once blocked
+// until released
+type blockingFeed struct {
+	mu      sync.Mutex
+	blocked bool
+	release chan struct{}
+}
+
+func (f *blockingFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	blocked := f.blocked
+	f.mu.Unlock()
+	if blocked {
+		<-f.release
+	}
+	fmt.Fprint(w, `<rss version="2.0"><channel><title>feed</title></channel></rss>`)
+}
+
+func (f *blockingFeed) block() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocked = true
+}
+
+func request(t *testing.T, h http.Handler, method, path string) (int, job) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+	var body struct {
+		Job job `json:"job"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected answer to %s %s: %s", method, path, rec.Body)
+	}
+	return rec.Code, body.Job
+}
+
+func TestUpdateJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	feed := &blockingFeed{release: make(chan struct{})}
+	feedSrv := httptest.NewServer(feed)
+	defer feedSrv.Close()
+
+	y, err := yrs.New("sqlite3", "file:"+t.TempDir()+"/yrs.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := y.Subscribe(feedSrv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Servers don't share anything but the Yrs
+	srv := NewServer(y, "", "/yrs", nil)
+	other := NewServer(y, "", "/other", nil)
+
+	feed.block()
+	code, started := request(t, srv.Handler, http.MethodPost, "/yrs/update")
+	if code != http.StatusAccepted || !started.Running() {
+		t.Fatalf("Expected the update to start. Got %d %+v", code, started)
+	}
+	code, running := request(t, srv.Handler, http.MethodPost, "/yrs/update")
+	if code != http.StatusConflict || running.ID != started.ID {
+		t.Errorf("Expected the running update instead of a new one. Got %d %+v", code, running)
+	}
+	if code, _ := request(t, other.Handler, http.MethodGet, "/other/update/"+started.ID); code != http.StatusNotFound {
+		t.Errorf("Expected the job to be unknown to the other server. Got %d", code)
+	}
+
+	// Shutting down waits for the update
+	close(feed.release)
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	code, finished := request(t, srv.Handler, http.MethodGet, "/yrs/update/"+started.ID)
+	if code != http.StatusOK || finished.State != jobFinished || finished.Finished == nil {
+		t.Errorf("Expected the update to be finished. Got %d %+v", code, finished)
+	}
+
+	code, again := request(t, srv.Handler, http.MethodPost, "/yrs/update")
+	if code != http.StatusAccepted || again.ID == started.ID {
+		t.Errorf("Expected a new update once finished. Got %d %+v", code, again)
+	}
+	srv.updates.wait()
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/miquelruiz/yrs/pkg/yrs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPushSize limits the content hubs can push
+const maxPushSize = 1 << 20
+
+// verifyWebSub answers the hub verifying a subscription with its challenge,
+// as long as yrs asked for it
+func (w *WebYrs) verifyWebSub(c *gin.Context) {
+	y := w.y
+	channel := c.Param("channel")
+	mode := c.Query("hub.mode")
+	lease, _ := strconv.Atoi(c.Query("hub.lease_seconds"))
+
+	err := y.VerifyWebSub(channel, mode, c.Query("hub.topic"), time.Duration(lease)*time.Second)
+	if err != nil {
+		slog.Warn("WebSub verification refused", "channel", channel, "mode", mode, "err", err)
+		c.String(http.StatusNotFound, "unknown subscription")
+		return
+	}
+
+	slog.Info("WebSub verified", "channel", channel, "mode", mode, "lease", lease)
+	c.String(http.StatusOK, c.Query("hub.challenge"))
+}
+
+// receiveWebSub records the videos pushed by the hub. Content with a wrong
+// signature is acknowledged anyway, as WebSub mandates, but ignored.
+func (w *WebYrs) receiveWebSub(c *gin.Context) {
+	y := w.y
+	channel := c.Param("channel")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPushSize))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	videos, err := y.ReceiveWebSub(channel, body, c.GetHeader("X-Hub-Signature"))
+	switch {
+	case errors.Is(err, yrs.ErrUnknownSubscription):
+		slog.Warn("WebSub push ignored", "channel", channel, "err", err)
+		c.Status(http.StatusGone)
+		return
+	case errors.Is(err, yrs.ErrInvalidSignature):
+		slog.Warn("WebSub push ignored", "channel", channel, "err", err)
+	case errors.Is(err, yrs.ErrNotification):
+		slog.Error("notification failed", "err", err)
+	case err != nil:
+		slog.Error("WebSub push failed", "channel", channel, "err", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if len(videos) > 0 {
+		slog.Info("WebSub pushed", "channel", channel, "new_videos", len(videos))
+	}
+	c.Status(http.StatusNoContent)
+}